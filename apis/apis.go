@@ -1,11 +1,13 @@
 package apis
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/ethereum/go-verkle"
@@ -18,9 +20,9 @@ import (
 	"github.com/RiemaLabs/modular-indexer-committee/ord/stateless"
 )
 
-func GetAllBalances(queue *stateless.Queue, tick string, pkScript string) ([]byte, []byte, Brc20VerifiableCurrentBalanceOfPkscriptResult) {
+func GetAllBalances(header stateless.KVStorage, tick string, pkScript string) ([]byte, []byte, Brc20VerifiableCurrentBalanceOfPkscriptResult) {
 	var ordPkscript ord.Pkscript = ord.Pkscript(pkScript)
-	availKey, overKey, availableBalance, overallBalance := stateless.GetBalances(queue.Header, tick, ordPkscript)
+	availKey, overKey, availableBalance, overallBalance := stateless.GetBalances(header, tick, ordPkscript)
 	availableBalanceStr := availableBalance.String()
 	overallBalanceStr := overallBalance.String()
 
@@ -32,17 +34,79 @@ func GetAllBalances(queue *stateless.Queue, tick string, pkScript string) ([]byt
 	return availKey, overKey, result
 }
 
-func GetCurrentBalanceOfWallet(c *gin.Context, queue *stateless.Queue) {
+// QueryState returns the state answering the query, the tip by default or the state at the height parameter.
+// The state is locked against the updates and the other queries until release is called, the proofs resolve
+// the nodes of the tree in place. It returns the HTTP status code along with the error.
+func QueryState(c *gin.Context, queue *stateless.Queue, history *stateless.History) (header *stateless.Header, historical bool, release func(), status int, err error) {
+	queue.RLock()
+	heightStr := c.DefaultQuery("height", "")
+	var height uint64
+	if heightStr != "" {
+		height, err = strconv.ParseUint(heightStr, 10, 64)
+		if err != nil {
+			queue.RUnlock()
+			return nil, false, nil, http.StatusBadRequest, fmt.Errorf("invalid height: %s", heightStr)
+		}
+	}
+	latestHeight := queue.LatestHeight()
+	if heightStr == "" || uint(height) == latestHeight {
+		header = queue.Header
+		header.Lock()
+		return header, false, func() {
+			header.Unlock()
+			queue.RUnlock()
+		}, http.StatusOK, nil
+	}
+	queue.RUnlock()
+	if uint(height) > latestHeight {
+		return nil, false, nil, http.StatusBadRequest, fmt.Errorf("the height %d is above the latest height %d", height, latestHeight)
+	}
+	if history == nil {
+		return nil, false, nil, http.StatusNotFound, errors.New("the historical states are not served")
+	}
+	header, err = history.StateAt(uint(height))
+	if err != nil {
+		return nil, false, nil, http.StatusNotFound, err
+	}
+	// The historical state is shared by the queries at the same height.
+	header.Lock()
+	return header, true, header.Unlock, http.StatusOK, nil
+}
+
+// historicalCommitment returns the height and the commitment of the historical state, both are nil for the tip.
+func historicalCommitment(header *stateless.Header, historical bool) (*uint, *string) {
+	if !historical {
+		return nil, nil
+	}
+	height := header.Height
+	bytes := header.Root.Commit().Bytes()
+	commitment := base64.StdEncoding.EncodeToString(bytes[:])
+	return &height, &commitment
+}
+
+func GetCurrentBalanceOfWallet(c *gin.Context, queue *stateless.Queue, history *stateless.History) {
 	tick := c.DefaultQuery("tick", "")
 	wallet := c.DefaultQuery("wallet", "")
 
-	_, pkScript := stateless.GetLatestPkscript(queue.Header, wallet)
+	header, historical, release, status, err := QueryState(c, queue, history)
+	if err != nil {
+		errStr := err.Error()
+		c.JSON(status, Brc20VerifiableCurrentBalanceOfWalletResponse{
+			Error:  &errStr,
+			Result: nil,
+			Proof:  nil,
+		})
+		return
+	}
+	defer release()
+
+	_, pkScript := stateless.GetLatestPkscript(header.Reader(), wallet)
 
-	availKey, overKey, result := GetAllBalances(queue, tick, pkScript)
+	availKey, overKey, result := GetAllBalances(header.Reader(), tick, pkScript)
 
 	keys := [][]byte{availKey, overKey}
 
-	proof, _, _, _, err := verkle.MakeVerkleMultiProof(queue.Header.Root, nil, keys, stateless.NodeResolveFn)
+	proof, _, _, _, err := verkle.MakeVerkleMultiProof(header.Root, nil, keys, stateless.NodeResolveFn)
 	if err != nil {
 		errStr := fmt.Sprintf("Failed to generate proof due to %v", err)
 		c.JSON(http.StatusInternalServerError, Brc20VerifiableCurrentBalanceOfWalletResponse{
@@ -81,21 +145,37 @@ func GetCurrentBalanceOfWallet(c *gin.Context, queue *stateless.Queue) {
 		Pkscript:         pkScript,
 	}
 
+	height, commitment := historicalCommitment(header, historical)
 	c.JSON(http.StatusOK, Brc20VerifiableCurrentBalanceOfWalletResponse{
-		Error:  nil,
-		Result: &resultWallet,
-		Proof:  &finalproof,
+		Error:      nil,
+		Result:     &resultWallet,
+		Proof:      &finalproof,
+		Height:     height,
+		Commitment: commitment,
 	})
 }
 
-func GetCurrentBalanceOfPkscript(c *gin.Context, queue *stateless.Queue) {
+func GetCurrentBalanceOfPkscript(c *gin.Context, queue *stateless.Queue, history *stateless.History) {
 	tick := c.DefaultQuery("tick", "")
 	pkScript := c.DefaultQuery("pkscript", "")
-	availKey, overKey, result := GetAllBalances(queue, tick, pkScript)
+
+	header, historical, release, status, err := QueryState(c, queue, history)
+	if err != nil {
+		errStr := err.Error()
+		c.JSON(status, Brc20VerifiableCurrentBalanceOfPkscriptResponse{
+			Error:  &errStr,
+			Result: nil,
+			Proof:  nil,
+		})
+		return
+	}
+	defer release()
+
+	availKey, overKey, result := GetAllBalances(header.Reader(), tick, pkScript)
 
 	keys := [][]byte{availKey, overKey}
 	// Generate proof
-	proofOfKeys, _, _, _, err := verkle.MakeVerkleMultiProof(queue.Header.Root, nil, keys, stateless.NodeResolveFn)
+	proofOfKeys, _, _, _, err := verkle.MakeVerkleMultiProof(header.Root, nil, keys, stateless.NodeResolveFn)
 	if err != nil {
 		errStr := fmt.Sprintf("Failed to generate proof due to %v", err)
 		c.JSON(http.StatusInternalServerError, Brc20VerifiableCurrentBalanceOfPkscriptResponse{
@@ -128,19 +208,26 @@ func GetCurrentBalanceOfPkscript(c *gin.Context, queue *stateless.Queue) {
 	}
 	finalproof := base64.StdEncoding.EncodeToString(vProofBytes[:])
 
+	height, commitment := historicalCommitment(header, historical)
 	c.JSON(http.StatusOK, Brc20VerifiableCurrentBalanceOfPkscriptResponse{
-		Error:  nil,
-		Result: &result,
-		Proof:  &finalproof,
+		Error:      nil,
+		Result:     &result,
+		Proof:      &finalproof,
+		Height:     height,
+		Commitment: commitment,
 	})
 }
 
 func GetBlockHeight(c *gin.Context, queue *stateless.Queue) {
+	queue.RLock()
 	curHeight := queue.LatestHeight()
+	queue.RUnlock()
 	c.Data(http.StatusOK, "text/plain", []byte(fmt.Sprintf("%d", curHeight)))
 }
 
 func GetLatestStateProof(c *gin.Context, queue *stateless.Queue) {
+	queue.RLock()
+	defer queue.RUnlock()
 	if queue.LastStateProof == nil {
 		c.JSON(http.StatusOK, Brc20VerifiableLatestStateProofResponse{
 			Error:  nil,
@@ -217,7 +304,12 @@ func GetLatestStateProof(c *gin.Context, queue *stateless.Queue) {
 	})
 }
 
-func StartService(queue *stateless.Queue, enableCommittee, enableDebug, enablePprof bool) {
+// The time given to the in-flight requests on shutdown.
+const shutdownTimeout = 15 * time.Second
+
+// StartService serves the APIs, the historical queries are rejected if history is nil.
+// It returns after ctx is done and the in-flight requests are drained.
+func StartService(ctx context.Context, queue *stateless.Queue, history *stateless.History, enableCommittee, enableDebug, enablePprof bool) {
 	if !enableDebug {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -237,11 +329,11 @@ func StartService(queue *stateless.Queue, enableCommittee, enableDebug, enablePp
 	}
 
 	r.GET("/v1/brc20_verifiable/current_balance_of_wallet", func(c *gin.Context) {
-		GetCurrentBalanceOfWallet(c, queue)
+		GetCurrentBalanceOfWallet(c, queue, history)
 	})
 
 	r.GET("/v1/brc20_verifiable/current_balance_of_pkscript", func(c *gin.Context) {
-		GetCurrentBalanceOfPkscript(c, queue)
+		GetCurrentBalanceOfPkscript(c, queue, history)
 	})
 
 	r.GET("/v1/brc20_verifiable/block_height", func(c *gin.Context) {
@@ -261,7 +353,23 @@ func StartService(queue *stateless.Queue, enableCommittee, enableDebug, enablePp
 	}
 
 	// TODO: Medium. Allow user to setup port.
-	if err := r.Run(":8080"); !errors.Is(err, http.ErrServerClosed) {
-		log.Fatal(err)
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+		return
+	case <-ctx.Done():
+	}
+	// Drain the in-flight requests before the state is persisted and closed.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to drain the API requests: %v", err)
 	}
 }
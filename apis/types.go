@@ -26,6 +26,8 @@ type Brc20VerifiableLatestStateProofResult struct {
 type Brc20VerifiableCurrentBalanceOfWalletRequest struct {
 	Tick   string `json:"tick"`
 	Wallet string `json:"wallet"`
+	// Query at a past height, the latest height by default.
+	Height *uint `json:"height,omitempty"`
 }
 
 type Brc20VerifiableCurrentBalanceOfWalletResult struct {
@@ -38,6 +40,9 @@ type Brc20VerifiableCurrentBalanceOfWalletResponse struct {
 	Error  *string                                      `json:"error"`
 	Result *Brc20VerifiableCurrentBalanceOfWalletResult `json:"result"`
 	Proof  *string                                      `json:"proof"`
+	// The height and the commitment of the state, only set for the queries at a past height.
+	Height     *uint   `json:"height,omitempty"`
+	Commitment *string `json:"commitment,omitempty"`
 }
 
 // Brc20VerifiableCurrentBalanceOfPkscript
@@ -45,6 +50,8 @@ type Brc20VerifiableCurrentBalanceOfWalletResponse struct {
 type Brc20VerifiableCurrentBalanceOfPkscriptRequest struct {
 	Tick     string `json:"tick"`
 	Pkscript string `json:"pkscript"`
+	// Query at a past height, the latest height by default.
+	Height *uint `json:"height,omitempty"`
 }
 
 type Brc20VerifiableCurrentBalanceOfPkscriptResult struct {
@@ -56,6 +63,9 @@ type Brc20VerifiableCurrentBalanceOfPkscriptResponse struct {
 	Error  *string                                        `json:"error"`
 	Result *Brc20VerifiableCurrentBalanceOfPkscriptResult `json:"result"`
 	Proof  *string                                        `json:"proof"`
+	// The height and the commitment of the state, only set for the queries at a past height.
+	Height     *uint   `json:"height,omitempty"`
+	Commitment *string `json:"commitment,omitempty"`
 }
 
 // Brc20VerifiableLatestStateProof
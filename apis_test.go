@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"io"
 	"log"
 	"testing"
@@ -25,7 +26,7 @@ func TestAPI_GetLatestStateProof_ZeroTransfers(t *testing.T) {
 
 func loadGetLatestStateProof(catchupHeight uint, t *testing.T) {
 	ordGetterTest, arguments := loadMain(782000)
-	queue, _ := CatchupStage(ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
+	queue, _ := CatchupStage(context.Background(), ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
 
 	// Set gin as test mode
 	gin.SetMode(gin.TestMode)
@@ -80,7 +81,7 @@ func TestAPI_VerifyCurrentBalanceOfPkscript(t *testing.T) {
 
 func loadVerifyCurrentBalanceOfPkscript(tick string, pkScript string, catchupHeight uint, t *testing.T) {
 	ordGetterTest, arguments := loadMain(782000)
-	queue, _ := CatchupStage(ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
+	queue, _ := CatchupStage(context.Background(), ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
 
 	// Get current balance from api
 	// Set gin as test mode
@@ -89,7 +90,7 @@ func loadVerifyCurrentBalanceOfPkscript(tick string, pkScript string, catchupHei
 	// register route
 	r := gin.Default()
 	r.GET("/v1/brc20_verifiable/current_balance_of_pkscript", func(c *gin.Context) {
-		apis.GetCurrentBalanceOfPkscript(c, queue)
+		apis.GetCurrentBalanceOfPkscript(c, queue, nil)
 	})
 
 	// create test server
@@ -138,7 +139,7 @@ func TestAPI_VerifyCurrentBalanceOfWallet(t *testing.T) {
 
 func loadVerifyCurrentBalanceOfWallet(tick string, wallet string, catchupHeight uint, t *testing.T, loadHeight uint) {
 	ordGetterTest, arguments := loadMain(loadHeight)
-	queue, _ := CatchupStage(ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
+	queue, _ := CatchupStage(context.Background(), ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
 
 	// Get current balance from api
 	// Set gin as test mode
@@ -147,7 +148,7 @@ func loadVerifyCurrentBalanceOfWallet(tick string, wallet string, catchupHeight
 	// register route
 	r := gin.Default()
 	r.GET("/v1/brc20_verifiable/current_balance_of_wallet", func(c *gin.Context) {
-		apis.GetCurrentBalanceOfWallet(c, queue)
+		apis.GetCurrentBalanceOfWallet(c, queue, nil)
 	})
 
 	// create test server
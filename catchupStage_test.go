@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"log"
 	"testing"
@@ -13,7 +14,7 @@ func Test_CatchupStage(t *testing.T) {
 	var catchupHeight uint = 780000
 	ordGetterTest, arguments := loadMain(782000)
 	startTime := time.Now()
-	queue, _ := CatchupStage(ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
+	queue, _ := CatchupStage(context.Background(), ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
 	if queue.Header.Height != catchupHeight {
 		log.Println("Queue header not updated correctly")
 	}
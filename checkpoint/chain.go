@@ -0,0 +1,57 @@
+package checkpoint
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ErrChainGap is returned by VerifyChain if a block is missing between two checkpoints.
+	ErrChainGap = errors.New("gap in the checkpoint chain")
+	// ErrChainFork is returned by VerifyChain if a checkpoint doesn't link to the previous one.
+	ErrChainFork = errors.New("fork in the checkpoint chain")
+)
+
+// Digest returns the hex of the SHA-256 of the canonical encoding of the checkpoint without its signature, it is
+// linked by the next checkpoint. The same digest is signed by Sign, see signingDigest.
+func (c *Checkpoint) Digest() string {
+	return hex.EncodeToString(c.signingDigest())
+}
+
+// Link chains the checkpoint to the checkpoint of the parent block, before it is signed.
+func (c *Checkpoint) Link(prev *Checkpoint) {
+	c.PrevHash = prev.Hash
+	c.PrevCheckpoint = prev.Digest()
+}
+
+// VerifyChain checks the checkpoints of an indexer ordered by the height are chained block by block, from the first one.
+// The checkpoints before schema version 2 carry no links and are rejected, the signatures are checked by Verify.
+func VerifyChain(checkpoints []Checkpoint) error {
+	for i := range checkpoints {
+		c := &checkpoints[i]
+		if c.SchemaVersion < 2 {
+			return fmt.Errorf("the checkpoint at height %s is of schema version %d without links", c.Height, max(c.SchemaVersion, 1))
+		}
+		if i == 0 {
+			continue
+		}
+		prev := &checkpoints[i-1]
+		height, err := strconv.ParseUint(c.Height, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid height of the checkpoint: %s", c.Height)
+		}
+		prevHeight, err := strconv.ParseUint(prev.Height, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid height of the checkpoint: %s", prev.Height)
+		}
+		if height != prevHeight+1 {
+			return fmt.Errorf("%w: from height %d to %d", ErrChainGap, prevHeight, height)
+		}
+		if c.PrevHash != prev.Hash || c.PrevCheckpoint != prev.Digest() {
+			return fmt.Errorf("%w: the checkpoint at height %d doesn't link to the previous one", ErrChainFork, height)
+		}
+	}
+	return nil
+}
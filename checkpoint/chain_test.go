@@ -0,0 +1,88 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func testChain(t *testing.T, length int) []Checkpoint {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexerID := IndexerIdentification{Name: "test", MetaProtocol: "brc-20", PublicKey: PublicKeyHex(key)}
+	var chain []Checkpoint
+	for i := 0; i < length; i++ {
+		c := NewCheckpoint(&indexerID, 780000+uint(i), "hash"+string(rune('a'+i)), "commitment")
+		c.TransfersDigest = "00"
+		if i > 0 {
+			c.Link(&chain[i-1])
+		}
+		Sign(&c, key)
+		chain = append(chain, c)
+	}
+	return chain
+}
+
+func TestVerifyChain(t *testing.T) {
+	chain := testChain(t, 4)
+	if err := VerifyChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	if chain[0].PrevCheckpoint != "" || chain[1].PrevHash != chain[0].Hash {
+		t.Fatal("unexpected links", chain[0].PrevCheckpoint, chain[1].PrevHash)
+	}
+	// The signature isn't part of the digest.
+	unsigned := chain[0]
+	unsigned.Signature = ""
+	if chain[0].Digest() != unsigned.Digest() {
+		t.Fatal("the digest depends on the signature")
+	}
+
+	// The chain survives the JSON round trip, and the legacy fields keep their names.
+	content, err := json.Marshal(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received []Checkpoint
+	if err := json.Unmarshal(content, &received); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChain(received); err != nil {
+		t.Fatal(err)
+	}
+	var fields []map[string]any
+	_ = json.Unmarshal(content, &fields)
+	for _, name := range []string{"commitment", "hash", "height", "metaProtocol", "name", "url", "version", "schemaVersion", "prevHash", "prevCheckpoint", "transfersDigest"} {
+		if _, found := fields[1][name]; !found {
+			t.Fatal("missing field", name)
+		}
+	}
+
+	gap := []Checkpoint{chain[0], chain[2], chain[3]}
+	if err := VerifyChain(gap); !errors.Is(err, ErrChainGap) {
+		t.Fatal("accepted a gap", err)
+	}
+	forked := append([]Checkpoint{}, chain...)
+	forked[1].Commitment = "forged"
+	if err := VerifyChain(forked); !errors.Is(err, ErrChainFork) {
+		t.Fatal("accepted a fork", err)
+	}
+	relinked := append([]Checkpoint{}, chain...)
+	relinked[2].PrevHash = "other"
+	if err := VerifyChain(relinked); !errors.Is(err, ErrChainFork) {
+		t.Fatal("accepted a checkpoint of another parent", err)
+	}
+
+	// The legacy checkpoints parse as schema version 1, without links.
+	var legacy Checkpoint
+	err = json.Unmarshal([]byte(`{"commitment":"c","hash":"h","height":"780000","metaProtocol":"brc-20","name":"test","url":"","version":"v0.1.0"}`), &legacy)
+	if err != nil || legacy.SchemaVersion != 0 {
+		t.Fatal("unexpected legacy checkpoint", legacy, err)
+	}
+	if err := VerifyChain([]Checkpoint{legacy}); err == nil {
+		t.Fatal("accepted a legacy checkpoint without links")
+	}
+}
@@ -0,0 +1,97 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// FileReporter writes the checkpoints into a local directory with the names of the S3 objects.
+// It publishes nothing, e.g. for a dry run on the staging nodes.
+type FileReporter struct {
+	// The name of the reporter, default to "file".
+	ReporterName string
+	Dir          string
+}
+
+func (r *FileReporter) Name() string {
+	if r.ReporterName == "" {
+		return "file"
+	}
+	return r.ReporterName
+}
+
+// Report writes the checkpoint to a temporary file and renames it, so a checkpoint file is never partial.
+func (r *FileReporter) Report(c *Checkpoint) error {
+	err := os.MkdirAll(r.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	checkpointJSON, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint to JSON: %w", err)
+	}
+	path := filepath.Join(r.Dir, objectName(c))
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, checkpointJSON, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	log.Printf("Checkpoint %s written successfully!", path)
+	return nil
+}
+
+// FileReader reads the checkpoints written by FileReporter.
+type FileReader struct {
+	Dir string
+}
+
+func (r *FileReader) List(q Query) ([]CheckpointInfo, error) {
+	entries, err := os.ReadDir(r.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var infos []CheckpointInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := ParseObjectName(entry.Name())
+		if err != nil || !q.Match(&info) {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+func (r *FileReader) Get(key string) (*Checkpoint, error) {
+	if filepath.Base(key) != key {
+		return nil, fmt.Errorf("invalid checkpoint key: %s", key)
+	}
+	content, err := os.ReadFile(filepath.Join(r.Dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrCheckpointNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c Checkpoint
+	err = json.Unmarshal(content, &c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", key, err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,304 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/RiemaLabs/modular-indexer-committee/internal/metrics"
+)
+
+var (
+	outboxBucket = []byte("outbox")
+	// The heights of the published checkpoints, kept after their entries are dropped from the outbox.
+	publishedBucket = []byte("published")
+)
+
+// OutboxEntry is a checkpoint with its upload status per destination, keyed by the name of the reporter.
+type OutboxEntry struct {
+	Height     uint
+	Checkpoint Checkpoint
+	Records    map[string]UploadRecord
+}
+
+// Pending returns the destinations the checkpoint isn't uploaded to yet and are due at now.
+func (e *OutboxEntry) Pending(now time.Time) []string {
+	destinations := make([]string, 0, len(e.Records))
+	for destination, record := range e.Records {
+		if !record.Success && !now.Before(record.NextAttempt) {
+			destinations = append(destinations, destination)
+		}
+	}
+	sort.Strings(destinations)
+	return destinations
+}
+
+// Outbox is an on-disk queue of the checkpoints to upload, keyed by the block height and hash.
+// The failed uploads are retried with exponential backoff, and the successful ones are never repeated, across restarts.
+type Outbox struct {
+	db         *bolt.DB
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+func NewOutbox(path string, minBackoff time.Duration, maxBackoff time.Duration) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0666, &bolt.Options{
+		Timeout:      time.Second,
+		FreelistType: bolt.FreelistMapType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the checkpoint outbox at %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(outboxBucket)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists(publishedBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	outbox := Outbox{
+		db:         db,
+		minBackoff: minBackoff,
+		maxBackoff: max(minBackoff, maxBackoff),
+	}
+	return &outbox, nil
+}
+
+func (o *Outbox) Close() error {
+	return o.db.Close()
+}
+
+func outboxKey(height uint, hash string) []byte {
+	key := make([]byte, 8, 8+len(hash))
+	binary.BigEndian.PutUint64(key, uint64(height))
+	return append(key, hash...)
+}
+
+func getEntry(b *bolt.Bucket, key []byte) (*OutboxEntry, error) {
+	v := b.Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	var entry OutboxEntry
+	err := gob.NewDecoder(bytes.NewReader(v)).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func putEntry(b *bolt.Bucket, entry *OutboxEntry) error {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(entry)
+	if err != nil {
+		return err
+	}
+	return b.Put(outboxKey(entry.Height, entry.Checkpoint.Hash), buf.Bytes())
+}
+
+// Enqueue adds the checkpoint of the block at height for the destinations, the existing records of the block are kept.
+func (o *Outbox) Enqueue(height uint, c Checkpoint, destinations []string) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		entry, err := getEntry(b, outboxKey(height, c.Hash))
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			entry = &OutboxEntry{
+				Height:     height,
+				Checkpoint: c,
+				Records:    make(map[string]UploadRecord),
+			}
+		}
+		added := false
+		for _, destination := range destinations {
+			if _, found := entry.Records[destination]; !found {
+				entry.Records[destination] = UploadRecord{}
+				added = true
+			}
+		}
+		if !added {
+			return nil
+		}
+		return putEntry(b, entry)
+	})
+}
+
+// Entry returns the entry of the block at height with hash, or nil if it isn't queued.
+func (o *Outbox) Entry(height uint, hash string) (*OutboxEntry, error) {
+	var entry *OutboxEntry
+	err := o.db.View(func(tx *bolt.Tx) error {
+		var err error
+		entry, err = getEntry(tx.Bucket(outboxBucket), outboxKey(height, hash))
+		return err
+	})
+	return entry, err
+}
+
+// Entries returns all entries ordered by the block height.
+func (o *Outbox) Entries() ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+	err := o.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(outboxBucket).ForEach(func(k, v []byte) error {
+			var entry OutboxEntry
+			err := gob.NewDecoder(bytes.NewReader(v)).Decode(&entry)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// Published returns the heights of the checkpoints uploaded to any destination, ordered by the height.
+// They outlive the entries dropped by Retain, e.g. to keep the snapshots at the published heights.
+func (o *Outbox) Published() ([]uint, error) {
+	heights := make([]uint, 0)
+	err := o.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(publishedBucket).ForEach(func(k, v []byte) error {
+			heights = append(heights, uint(binary.BigEndian.Uint64(k)))
+			return nil
+		})
+	})
+	return heights, err
+}
+
+// backoff returns the wait before the next attempt after the given number of failed attempts.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	backoff := o.minBackoff
+	for i := 1; i < attempts && backoff < o.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, o.maxBackoff)
+}
+
+// record stores the result of an upload attempt at now.
+func (o *Outbox) record(height uint, hash string, destination string, uploadErr error, now time.Time) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		entry, err := getEntry(b, outboxKey(height, hash))
+		if err != nil || entry == nil {
+			return err
+		}
+		record := entry.Records[destination]
+		record.Attempts++
+		record.LastAttempt = now
+		if uploadErr == nil {
+			record.Success = true
+			record.LastError = ""
+			err := tx.Bucket(publishedBucket).Put(outboxKey(height, ""), nil)
+			if err != nil {
+				return err
+			}
+		} else {
+			record.LastError = uploadErr.Error()
+			record.NextAttempt = now.Add(o.backoff(record.Attempts))
+		}
+		entry.Records[destination] = record
+		return putEntry(b, entry)
+	})
+}
+
+// Deliver reports the checkpoints pending at now by the reporters of their destinations and records the results.
+// The failed uploads are logged and retried by a later call once their backoff passes.
+func (o *Outbox) Deliver(reporters []Reporter, now time.Time) error {
+	entries, err := o.Entries()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]Reporter, len(reporters))
+	for _, reporter := range reporters {
+		byName[reporter.Name()] = reporter
+	}
+	pending := 0
+	for _, entry := range entries {
+		for _, destination := range entry.Pending(now) {
+			reporter, found := byName[destination]
+			if !found {
+				continue
+			}
+			c := entry.Checkpoint
+			log.Printf("Uploading the checkpoint by %s at height: %s", destination, c.Height)
+			uploadErr := reporter.Report(&c)
+			if uploadErr != nil {
+				log.Printf("Unable to upload the checkpoint by %s at height %s, attempt %d: %v",
+					destination, c.Height, entry.Records[destination].Attempts+1, uploadErr)
+				metrics.CheckpointUploads.WithLabelValues(destination, "failure").Inc()
+				pending++
+			} else {
+				log.Printf("Succeed to upload the checkpoint by %s at height: %s", destination, c.Height)
+				metrics.CheckpointUploads.WithLabelValues(destination, "success").Inc()
+			}
+			err := o.record(entry.Height, c.Hash, destination, uploadErr, now)
+			if err != nil {
+				return err
+			}
+		}
+		for _, record := range entry.Records {
+			if !record.Success && now.Before(record.NextAttempt) {
+				pending++
+			}
+		}
+	}
+	metrics.CheckpointPending.Set(float64(pending))
+	return nil
+}
+
+// Retain drops the entries whose block isn't in hashes, keyed by the height: the ones below the reorg window
+// and the ones abandoned by a reorg. It returns the number of dropped entries not uploaded to all destinations.
+func (o *Outbox) Retain(hashes map[uint]string) (int, error) {
+	undelivered := 0
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		// The keys are deleted after the iteration, deleting by the cursor may skip the next key.
+		var dropped [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			height := uint(binary.BigEndian.Uint64(k[:8]))
+			if hash, found := hashes[height]; found && hash == string(k[8:]) {
+				return nil
+			}
+			var entry OutboxEntry
+			err := gob.NewDecoder(bytes.NewReader(v)).Decode(&entry)
+			if err != nil {
+				return err
+			}
+			for _, record := range entry.Records {
+				if !record.Success {
+					undelivered++
+					break
+				}
+			}
+			dropped = append(dropped, append([]byte(nil), k...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range dropped {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return undelivered, err
+}
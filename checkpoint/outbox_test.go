@@ -0,0 +1,128 @@
+package checkpoint
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type funcReporter struct {
+	name   string
+	report func(c *Checkpoint) error
+}
+
+func (r *funcReporter) Name() string {
+	return r.name
+}
+
+func (r *funcReporter) Report(c *Checkpoint) error {
+	return r.report(c)
+}
+
+func TestOutbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	outbox, err := NewOutbox(path, time.Second, 4*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexerID := IndexerIdentification{Name: "test", MetaProtocol: "brc-20"}
+	for height := uint(10); height <= 12; height++ {
+		c := NewCheckpoint(&indexerID, height, "hash"+string(rune('a'+height-10)), "commitment")
+		if err := outbox.Enqueue(height, c, []string{"S3"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uploads := make(map[string]int)
+	failing := map[string]bool{"11": true}
+	reporters := []Reporter{&funcReporter{name: "S3", report: func(c *Checkpoint) error {
+		uploads[c.Height]++
+		if failing[c.Height] {
+			return errors.New("timeout")
+		}
+		return nil
+	}}}
+	now := time.Now()
+	if err := outbox.Deliver(reporters, now); err != nil {
+		t.Fatal(err)
+	}
+	if uploads["10"] != 1 || uploads["11"] != 1 || uploads["12"] != 1 {
+		t.Fatal("unexpected uploads", uploads)
+	}
+
+	// The failed upload waits for its backoff, the successful ones are never repeated.
+	if err := outbox.Deliver(reporters, now.Add(500*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if uploads["11"] != 1 {
+		t.Fatal("the backoff isn't respected", uploads)
+	}
+	if err := outbox.Deliver(reporters, now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if uploads["10"] != 1 || uploads["11"] != 2 {
+		t.Fatal("unexpected retries", uploads)
+	}
+	// The backoff doubles after the second failure.
+	if err := outbox.Deliver(reporters, now.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if uploads["11"] != 2 {
+		t.Fatal("the backoff doesn't grow", uploads)
+	}
+
+	// The status survives a restart.
+	if err := outbox.Close(); err != nil {
+		t.Fatal(err)
+	}
+	outbox, err = NewOutbox(path, time.Second, 4*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+	c := NewCheckpoint(&indexerID, 10, "hasha", "commitment")
+	if err := outbox.Enqueue(10, c, []string{"S3", "DA"}); err != nil {
+		t.Fatal(err)
+	}
+	failing["11"] = false
+	if err := outbox.Deliver(reporters, now.Add(3*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if uploads["10"] != 1 || uploads["11"] != 3 || uploads["12"] != 1 {
+		t.Fatal("unexpected uploads after the restart", uploads)
+	}
+	entries, err := outbox.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := entries[1].Records["S3"]
+	if len(entries) != 3 || !record.Success || record.Attempts != 3 || record.LastError != "" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	// There is no reporter of DA, it stays pending.
+	if pending := entries[0].Pending(now.Add(3 * time.Second)); len(pending) != 1 || pending[0] != "DA" {
+		t.Fatal("unexpected pending destinations", pending)
+	}
+
+	// The block 12 is abandoned by a reorg and the block 10 falls out of the window.
+	undelivered, err := outbox.Retain(map[uint]string{11: "hashb", 12: "hashz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err = outbox.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undelivered != 1 || len(entries) != 1 || entries[0].Height != 11 {
+		t.Fatalf("unexpected entries after the retention: %d, %+v", undelivered, entries)
+	}
+	// The heights of the published checkpoints outlive their entries.
+	published, err := outbox.Published()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 3 || published[0] != 10 || published[1] != 11 || published[2] != 12 {
+		t.Fatal("unexpected published heights", published)
+	}
+}
@@ -0,0 +1,349 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/RiemaLabs/nubit-da-sdk"
+	"github.com/RiemaLabs/nubit-da-sdk/constant"
+	"github.com/RiemaLabs/nubit-da-sdk/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrCheckpointNotFound is returned if no checkpoint is stored under the key.
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// The meta protocols containing a dash, the object names are split around them.
+var metaProtocols = []string{"brc-20"}
+
+// CheckpointInfo locates a stored checkpoint, Key is the object name in S3 and in a directory, and the data ID in DA.
+type CheckpointInfo struct {
+	Key          string
+	Name         string
+	MetaProtocol string
+	Height       uint
+	Hash         string
+}
+
+// ParseObjectName parses the name `checkpoint-<name>-<protocol>-<height>-<hash>.json` of an uploaded checkpoint.
+// The name of the indexer may contain dashes, the protocol may only if it is a known one like brc-20.
+func ParseObjectName(key string) (CheckpointInfo, error) {
+	trimmed, found := strings.CutPrefix(key, "checkpoint-")
+	if !found {
+		return CheckpointInfo{}, fmt.Errorf("not a checkpoint object: %s", key)
+	}
+	trimmed, found = strings.CutSuffix(trimmed, ".json")
+	if !found {
+		return CheckpointInfo{}, fmt.Errorf("not a checkpoint object: %s", key)
+	}
+	i := strings.LastIndexByte(trimmed, '-')
+	if i < 0 {
+		return CheckpointInfo{}, fmt.Errorf("invalid checkpoint object: %s", key)
+	}
+	hash := trimmed[i+1:]
+	trimmed = trimmed[:i]
+	i = strings.LastIndexByte(trimmed, '-')
+	if i < 0 {
+		return CheckpointInfo{}, fmt.Errorf("invalid checkpoint object: %s", key)
+	}
+	height, err := strconv.ParseUint(trimmed[i+1:], 10, 64)
+	if err != nil || hash == "" {
+		return CheckpointInfo{}, fmt.Errorf("invalid checkpoint object: %s", key)
+	}
+	trimmed = trimmed[:i]
+
+	split := strings.LastIndexByte(trimmed, '-')
+	for _, protocol := range metaProtocols {
+		if strings.HasSuffix(trimmed, "-"+protocol) {
+			split = len(trimmed) - len(protocol) - 1
+			break
+		}
+	}
+	if split <= 0 || split == len(trimmed)-1 {
+		return CheckpointInfo{}, fmt.Errorf("invalid checkpoint object: %s", key)
+	}
+	return CheckpointInfo{
+		Key:          key,
+		Name:         trimmed[:split],
+		MetaProtocol: trimmed[split+1:],
+		Height:       uint(height),
+		Hash:         hash,
+	}, nil
+}
+
+// Query selects the checkpoints, the zero fields match any.
+type Query struct {
+	Name         string
+	MetaProtocol string
+	Height       uint
+	Hash         string
+}
+
+func (q *Query) Match(info *CheckpointInfo) bool {
+	return (q.Name == "" || q.Name == info.Name) &&
+		(q.MetaProtocol == "" || strings.EqualFold(q.MetaProtocol, info.MetaProtocol)) &&
+		(q.Height == 0 || q.Height == info.Height) &&
+		(q.Hash == "" || q.Hash == info.Hash)
+}
+
+// Reader reads back the checkpoints published by a reporter.
+type Reader interface {
+	// List returns the checkpoints matching the query, ordered by the height.
+	List(q Query) ([]CheckpointInfo, error)
+	// Get returns the checkpoint stored under the key, or ErrCheckpointNotFound.
+	Get(key string) (*Checkpoint, error)
+}
+
+func sortInfos(infos []CheckpointInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Height != infos[j].Height {
+			return infos[i].Height < infos[j].Height
+		}
+		return infos[i].Key < infos[j].Key
+	})
+}
+
+// Find fetches the checkpoints matching the query. The checkpoints whose content doesn't match their key are
+// rejected, the signatures are left to be checked by Verify.
+func Find(r Reader, q Query) ([]Checkpoint, error) {
+	infos, err := r.List(q)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]Checkpoint, 0, len(infos))
+	for _, info := range infos {
+		c, err := r.Get(info.Key)
+		if err != nil {
+			return nil, err
+		}
+		if c.Name != info.Name || c.MetaProtocol != info.MetaProtocol || c.Height != strconv.FormatUint(uint64(info.Height), 10) || c.Hash != info.Hash {
+			return nil, fmt.Errorf("the checkpoint %s doesn't match its content", info.Key)
+		}
+		checkpoints = append(checkpoints, *c)
+	}
+	return checkpoints, nil
+}
+
+// S3Reader reads the checkpoints uploaded by S3Reporter.
+type S3Reader struct {
+	Client  *s3.Client
+	Bucket  string
+	Timeout time.Duration
+}
+
+// NewS3Reader reads the checkpoints from the bucket, endpoint is optional and selects an S3-compatible service
+// addressed by path, e.g. MinIO.
+func NewS3Reader(accessKey, secretKey, region, bucket, endpoint string, timeout time.Duration) (*S3Reader, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aws config, error: %v", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Reader{Client: client, Bucket: bucket, Timeout: timeout}, nil
+}
+
+func (r *S3Reader) List(q Query) ([]CheckpointInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	prefix := "checkpoint-"
+	if q.Name != "" {
+		prefix += q.Name + "-"
+	}
+	var infos []CheckpointInfo
+	pages := s3.NewListObjectsV2Paginator(r.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the checkpoints: %w", err)
+		}
+		for _, object := range page.Contents {
+			info, err := ParseObjectName(aws.ToString(object.Key))
+			if err != nil || !q.Match(&info) {
+				continue
+			}
+			infos = append(infos, info)
+		}
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+func (r *S3Reader) Get(key string) (*Checkpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	output, err := r.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.Bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, fmt.Errorf("%w: %s", ErrCheckpointNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the checkpoint %s: %w", key, err)
+	}
+	defer output.Body.Close()
+	content, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, err
+	}
+	var c Checkpoint
+	err = json.Unmarshal(content, &c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", key, err)
+	}
+	return &c, nil
+}
+
+// DAClient is the part of the Nubit client reading the data of a namespace.
+type DAClient interface {
+	GetDataInNamespace(ctx context.Context, req *types.GetDataInNamespaceReq) (*types.GetDataInNamespaceRsp, error)
+	GetData(ctx context.Context, req *types.GetDataReq) (*types.GetDataRsp, error)
+}
+
+// DAReader reads the checkpoints uploaded by DAReporter. The blobs carry no name, so List fetches the whole
+// namespace, the blobs are immutable and cached by their data ID, including the ones of other data.
+type DAReader struct {
+	Client      DAClient
+	NamespaceID string
+	Timeout     time.Duration
+	// The number of data IDs listed by a request, default to 100.
+	PageSize int
+
+	mu    sync.Mutex
+	blobs map[string]*Checkpoint
+}
+
+// NewDAReader reads the checkpoints from the namespace of the network, no private key is required.
+func NewDAReader(namespaceID, network string, timeout time.Duration) (*DAReader, error) {
+	if network == "Pre-Alpha Testnet" {
+		sdk.SetNet(constant.PreAlphaTestNet)
+	} else if network == "Testnet" {
+		sdk.SetNet(constant.TestNet)
+	} else {
+		return nil, fmt.Errorf("unknown network: %s", network)
+	}
+	clientDA := sdk.NewNubit()
+	if clientDA == nil || clientDA.Client == nil {
+		return nil, fmt.Errorf("failed to build the Nubit client")
+	}
+	return &DAReader{Client: clientDA.Client, NamespaceID: namespaceID, Timeout: timeout}, nil
+}
+
+func (r *DAReader) List(q Query) ([]CheckpointInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	pageSize := r.PageSize
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	var infos []CheckpointInfo
+	for offset := 0; ; offset += pageSize {
+		page, err := r.Client.GetDataInNamespace(ctx, &types.GetDataInNamespaceReq{
+			NID:    r.NamespaceID,
+			Limit:  pageSize,
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the data in the namespace %s: %w", r.NamespaceID, err)
+		}
+		for _, dataID := range page.DataIDs {
+			c, err := r.get(ctx, dataID)
+			if err != nil {
+				// The namespace may hold other data than the checkpoints.
+				if errors.Is(err, errNotCheckpoint) {
+					continue
+				}
+				return nil, err
+			}
+			height, err := strconv.ParseUint(c.Height, 10, 64)
+			if err != nil {
+				continue
+			}
+			info := CheckpointInfo{
+				Key:          dataID,
+				Name:         c.Name,
+				MetaProtocol: c.MetaProtocol,
+				Height:       uint(height),
+				Hash:         c.Hash,
+			}
+			if q.Match(&info) {
+				infos = append(infos, info)
+			}
+		}
+		if len(page.DataIDs) < pageSize {
+			break
+		}
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+func (r *DAReader) Get(key string) (*Checkpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	return r.get(ctx, key)
+}
+
+var errNotCheckpoint = errors.New("not a checkpoint")
+
+func (r *DAReader) get(ctx context.Context, dataID string) (*Checkpoint, error) {
+	r.mu.Lock()
+	c, found := r.blobs[dataID]
+	r.mu.Unlock()
+	if found && c == nil {
+		return nil, fmt.Errorf("%w: %s", errNotCheckpoint, dataID)
+	}
+	if found {
+		return c, nil
+	}
+	data, err := r.Client.GetData(ctx, &types.GetDataReq{DAID: dataID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the data %s: %w", dataID, err)
+	}
+	if data == nil || data.DataID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrCheckpointNotFound, dataID)
+	}
+	// The data is uploaded in base64 by UploadBytes.
+	c = &Checkpoint{}
+	content, err := base64.StdEncoding.DecodeString(data.RawData)
+	if err != nil || json.Unmarshal(content, c) != nil || c.Height == "" || c.Hash == "" {
+		c = nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.blobs == nil {
+		r.blobs = make(map[string]*Checkpoint)
+	}
+	r.blobs[dataID] = c
+	if c == nil {
+		return nil, fmt.Errorf("%w: %s", errNotCheckpoint, dataID)
+	}
+	return c, nil
+}
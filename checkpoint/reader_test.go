@@ -0,0 +1,256 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RiemaLabs/nubit-da-sdk/types"
+)
+
+func TestParseObjectName(t *testing.T) {
+	cases := map[string]CheckpointInfo{
+		"checkpoint-test-brc-20-780000-hash.json":      {Name: "test", MetaProtocol: "brc-20", Height: 780000, Hash: "hash"},
+		"checkpoint-nubit-indexer-brc-20-1-00ff.json":  {Name: "nubit-indexer", MetaProtocol: "brc-20", Height: 1, Hash: "00ff"},
+		"checkpoint-indexer-runes-840000-abcd.json":    {Name: "indexer", MetaProtocol: "runes", Height: 840000, Hash: "abcd"},
+		"checkpoint-my-indexer-runes-840000-abcd.json": {Name: "my-indexer", MetaProtocol: "runes", Height: 840000, Hash: "abcd"},
+		"checkpoint-test-brc-20-780000-hash.json.tmp":  {},
+		"checkpoint-test-brc-20-height-hash.json":      {},
+		"checkpoint--brc-20-780000-hash.json":          {},
+		"snapshot-test-brc-20-780000-hash.json":        {},
+		"checkpoint-test-brc-20-780000-.json":          {},
+	}
+	for key, expected := range cases {
+		info, err := ParseObjectName(key)
+		if expected.Name == "" {
+			if err == nil {
+				t.Errorf("parsed the invalid name %s: %+v", key, info)
+			}
+			continue
+		}
+		expected.Key = key
+		if err != nil || info != expected {
+			t.Errorf("unexpected info of %s: %+v, %v", key, info, err)
+		}
+	}
+}
+
+func testCheckpoints() []Checkpoint {
+	return []Checkpoint{
+		NewCheckpoint(&IndexerIdentification{Name: "alice", MetaProtocol: "brc-20"}, 780001, "hash1", "commitment1"),
+		NewCheckpoint(&IndexerIdentification{Name: "alice", MetaProtocol: "brc-20"}, 780000, "hash0", "commitment0"),
+		NewCheckpoint(&IndexerIdentification{Name: "bob-2", MetaProtocol: "brc-20"}, 780000, "hash0", "commitment0"),
+		NewCheckpoint(&IndexerIdentification{Name: "bob-2", MetaProtocol: "brc-20"}, 780001, "forked", "commitment2"),
+	}
+}
+
+// testReader checks the reader holding testCheckpoints.
+func testReader(t *testing.T, r Reader) {
+	t.Helper()
+	all, err := r.List(Query{})
+	if err != nil || len(all) != 4 {
+		t.Fatal("unexpected checkpoints", all, err)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Height < all[i-1].Height {
+			t.Fatal("unordered checkpoints", all)
+		}
+	}
+
+	checkpoints, err := Find(r, Query{Name: "alice"})
+	if err != nil || len(checkpoints) != 2 || checkpoints[0].Height != "780000" || checkpoints[1].Commitment != "commitment1" {
+		t.Fatal("unexpected checkpoints of alice", checkpoints, err)
+	}
+	checkpoints, err = Find(r, Query{Height: 780001, MetaProtocol: "BRC-20"})
+	if err != nil || len(checkpoints) != 2 {
+		t.Fatal("unexpected checkpoints at height 780001", checkpoints, err)
+	}
+	checkpoints, err = Find(r, Query{Hash: "forked"})
+	if err != nil || len(checkpoints) != 1 || checkpoints[0].Name != "bob-2" {
+		t.Fatal("unexpected checkpoints of the fork", checkpoints, err)
+	}
+	checkpoints, err = Find(r, Query{Name: "carol"})
+	if err != nil || len(checkpoints) != 0 {
+		t.Fatal("unexpected checkpoints of carol", checkpoints, err)
+	}
+	if _, err := r.Get("checkpoint-carol-brc-20-780000-hash0.json"); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Fatal("found a missing checkpoint", err)
+	}
+}
+
+func TestFileReader(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	if infos, err := (&FileReader{Dir: dir}).List(Query{}); err != nil || len(infos) != 0 {
+		t.Fatal("unexpected checkpoints in a missing directory", infos, err)
+	}
+	reporter := &FileReporter{Dir: dir}
+	for _, c := range testCheckpoints() {
+		if err := reporter.Report(&c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testReader(t, &FileReader{Dir: dir})
+}
+
+// s3StandIn is an S3-compatible service of a single bucket addressed by path, serving ListObjectsV2 in pages
+// and GetObject.
+type s3StandIn struct {
+	bucket   string
+	pageSize int
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *s3StandIn) put(key string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.objects == nil {
+		s.objects = make(map[string][]byte)
+	}
+	s.objects[key] = content
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodGet || bucket != s.bucket {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	if key != "" {
+		content, found := s.objects[key]
+		if !found {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		_, _ = w.Write(content)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	keys := make([]string, 0, len(s.objects))
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	offset, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+	type object struct {
+		Key  string
+		Size int
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string   `xml:",omitempty"`
+		Contents              []object `xml:"Contents"`
+	}{Name: s.bucket, Prefix: prefix}
+	for _, k := range keys[min(offset, len(keys)):min(offset+s.pageSize, len(keys))] {
+		result.Contents = append(result.Contents, object{Key: k, Size: len(s.objects[k])})
+	}
+	result.KeyCount = len(result.Contents)
+	if offset+s.pageSize < len(keys) {
+		result.IsTruncated = true
+		result.NextContinuationToken = strconv.Itoa(offset + s.pageSize)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func TestS3Reader(t *testing.T) {
+	standIn := &s3StandIn{bucket: "checkpoints", pageSize: 2}
+	for _, c := range testCheckpoints() {
+		content, _ := json.Marshal(&c)
+		standIn.put(objectName(&c), content)
+	}
+	standIn.put("README.md", []byte("not a checkpoint"))
+	ts := httptest.NewServer(standIn)
+	defer ts.Close()
+
+	r, err := NewS3Reader("access", "secret", "us-east-1", "checkpoints", ts.URL, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testReader(t, r)
+
+	// The content must match the key.
+	standIn.put("checkpoint-mallory-brc-20-780000-hash0.json", standIn.objects[objectName(&testCheckpoints()[1])])
+	if _, err := Find(r, Query{Name: "mallory"}); err == nil {
+		t.Fatal("accepted a checkpoint under another key")
+	}
+}
+
+// fakeDAClient is a namespace of the DA layer holding the uploaded data in base64, like UploadBytes.
+type fakeDAClient struct {
+	namespaceID string
+	dataIDs     []string
+	data        map[string]string
+	gets        int
+}
+
+func (f *fakeDAClient) upload(content []byte) {
+	if f.data == nil {
+		f.data = make(map[string]string)
+	}
+	dataID := "0x" + strconv.Itoa(len(f.dataIDs))
+	f.dataIDs = append(f.dataIDs, dataID)
+	f.data[dataID] = base64.StdEncoding.EncodeToString(content)
+}
+
+func (f *fakeDAClient) GetDataInNamespace(ctx context.Context, req *types.GetDataInNamespaceReq) (*types.GetDataInNamespaceRsp, error) {
+	if req.NID != f.namespaceID {
+		return &types.GetDataInNamespaceRsp{}, nil
+	}
+	from := min(req.Offset, len(f.dataIDs))
+	to := min(req.Offset+req.Limit, len(f.dataIDs))
+	return &types.GetDataInNamespaceRsp{DataIDs: f.dataIDs[from:to], LastOffset: int64(to)}, nil
+}
+
+func (f *fakeDAClient) GetData(ctx context.Context, req *types.GetDataReq) (*types.GetDataRsp, error) {
+	f.gets++
+	rawData, found := f.data[req.DAID]
+	if !found {
+		return &types.GetDataRsp{}, nil
+	}
+	return &types.GetDataRsp{DataID: req.DAID, NID: f.namespaceID, RawData: rawData}, nil
+}
+
+func TestDAReader(t *testing.T) {
+	client := &fakeDAClient{namespaceID: "0x1"}
+	for _, c := range testCheckpoints() {
+		content, _ := json.Marshal(&c)
+		client.upload(content)
+	}
+	client.upload([]byte("not a checkpoint"))
+	r := &DAReader{Client: client, NamespaceID: "0x1", Timeout: time.Second, PageSize: 2}
+	testReader(t, r)
+
+	// The blobs are fetched once, and the missing one by Get.
+	if client.gets != len(client.dataIDs)+1 {
+		t.Fatal("unexpected number of fetched blobs", client.gets)
+	}
+	c, err := r.Get("0x0")
+	if err != nil || c.Height != "780001" || c.Name != "alice" {
+		t.Fatal("unexpected checkpoint", c, err)
+	}
+}
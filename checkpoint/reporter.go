@@ -21,16 +21,74 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+// Reporter publishes the checkpoints to a destination.
+type Reporter interface {
+	// Name identifies the destination in the outbox, it must stay the same across restarts.
+	Name() string
+	Report(c *Checkpoint) error
+}
+
+// S3Reporter uploads the checkpoints to an AWS S3 bucket.
+type S3Reporter struct {
+	// The name of the reporter, default to "S3".
+	ReporterName string
+	AccessKey    string
+	SecretKey    string
+	Region       string
+	Bucket       string
+	Timeout      time.Duration
+}
+
+func (r *S3Reporter) Name() string {
+	if r.ReporterName == "" {
+		return "S3"
+	}
+	return r.ReporterName
+}
+
+func (r *S3Reporter) Report(c *Checkpoint) error {
+	return UploadCheckpointByS3(c, r.AccessKey, r.SecretKey, r.Region, r.Bucket, r.Timeout)
+}
+
+// DAReporter uploads the checkpoints to a namespace of the Nubit DA layer.
+type DAReporter struct {
+	// The name of the reporter, default to "DA".
+	ReporterName string
+	PrivateKey   string
+	GasCoupon    string
+	NamespaceID  string
+	Network      string
+	Timeout      time.Duration
+}
+
+func (r *DAReporter) Name() string {
+	if r.ReporterName == "" {
+		return "DA"
+	}
+	return r.ReporterName
+}
+
+func (r *DAReporter) Report(c *Checkpoint) error {
+	return UploadCheckpointByDA(c, r.PrivateKey, r.GasCoupon, r.NamespaceID, r.Network, r.Timeout)
+}
+
+// objectName is the name of the checkpoint in the S3 bucket and in the directory of FileReporter.
+func objectName(c *Checkpoint) string {
+	return fmt.Sprintf("checkpoint-%s-%s-%s-%s.json", c.Name, c.MetaProtocol, c.Height, c.Hash)
+}
+
 func NewCheckpoint(indexID *IndexerIdentification, height uint, hash string, commitment string) Checkpoint {
 	blockHeight := fmt.Sprintf("%d", height)
 	content := Checkpoint{
-		URL:          indexID.URL,
-		Name:         indexID.Name,
-		Version:      indexID.Version,
-		MetaProtocol: indexID.MetaProtocol,
-		Height:       blockHeight,
-		Hash:         hash,
-		Commitment:   commitment,
+		SchemaVersion: CurrentSchemaVersion,
+		URL:           indexID.URL,
+		Name:          indexID.Name,
+		Version:       indexID.Version,
+		MetaProtocol:  indexID.MetaProtocol,
+		Height:        blockHeight,
+		Hash:          hash,
+		Commitment:    commitment,
+		PublicKey:     indexID.PublicKey,
 	}
 	return content
 }
@@ -135,7 +193,7 @@ func UploadCheckpointByS3(c *Checkpoint, accessKey, secretKey, region, bucket st
 	var awsS3Client = s3.NewFromConfig(cfg)
 	uploader := manager.NewUploader(awsS3Client)
 
-	objectKey := fmt.Sprintf("checkpoint-%s-%s-%s-%s.json", c.Name, c.MetaProtocol, c.Height, c.Hash)
+	objectKey := objectName(c)
 
 	checkpointJSON, err := json.Marshal(c)
 	if err != nil {
@@ -0,0 +1,68 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileReporter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	r := &FileReporter{Dir: dir}
+	c := NewCheckpoint(&IndexerIdentification{Name: "test", MetaProtocol: "brc-20"}, 780000, "hash", "commitment")
+	if err := r.Report(&c); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "checkpoint-test-brc-20-780000-hash.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written Checkpoint
+	if err := json.Unmarshal(content, &written); err != nil || written != c {
+		t.Fatal("unexpected checkpoint", written, err)
+	}
+	if r.Name() != "file" || (&FileReporter{ReporterName: "dry-run"}).Name() != "dry-run" {
+		t.Fatal("unexpected reporter names")
+	}
+}
+
+func TestWebhookReporter(t *testing.T) {
+	var received []Checkpoint
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var c Checkpoint
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, c)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	r := &WebhookReporter{
+		URL:     ts.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Timeout: time.Second,
+		Client:  ts.Client(),
+	}
+	c := NewCheckpoint(&IndexerIdentification{Name: "test", MetaProtocol: "brc-20"}, 780000, "hash", "commitment")
+	if err := r.Report(&c); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0] != c {
+		t.Fatal("unexpected checkpoints", received)
+	}
+	status = http.StatusServiceUnavailable
+	if err := r.Report(&c); err == nil {
+		t.Fatal("the failed response is accepted")
+	}
+}
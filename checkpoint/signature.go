@@ -0,0 +1,128 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+)
+
+var (
+	// ErrUnsigned is returned by Verify if the checkpoint carries no signature.
+	ErrUnsigned = errors.New("checkpoint is unsigned")
+	// ErrInvalidSignature is returned by Verify if the signature doesn't match the checkpoint and its public key.
+	ErrInvalidSignature = errors.New("invalid checkpoint signature")
+	// ErrUnexpectedKey is returned by Verify if the checkpoint is signed by another key than the expected one.
+	ErrUnexpectedKey = errors.New("checkpoint is signed by an unexpected key")
+)
+
+// GenerateKey generates a secp256k1 identity key of an indexer.
+func GenerateKey() (*btcec.PrivateKey, error) {
+	return btcec.NewPrivateKey()
+}
+
+// PublicKeyHex returns the compressed public key in hex, as carried by the checkpoints.
+func PublicKeyHex(key *btcec.PrivateKey) string {
+	return hex.EncodeToString(key.PubKey().SerializeCompressed())
+}
+
+// SaveKey writes the private key in hex, readable by the owner only. An existing key is never overwritten.
+func SaveKey(path string, key *btcec.PrivateKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(hex.EncodeToString(key.Serialize()) + "\n")
+	return errors.Join(err, file.Close())
+}
+
+// LoadKey reads the private key written by SaveKey.
+func LoadKey(path string) (*btcec.PrivateKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(raw) != btcec.PrivKeyBytesLen {
+		return nil, fmt.Errorf("invalid private key in %s", path)
+	}
+	key, _ := btcec.PrivKeyFromBytes(raw)
+	return key, nil
+}
+
+// signingDomain separates the signing digests of the checkpoints from the other messages signed by the key.
+const signingDomain = "modular-indexer-checkpoint"
+
+// signingDigest is the SHA-256 of the canonical encoding of the checkpoint without its signature: the signing
+// domain, then the fields in the fixed order SchemaVersion, Commitment, Hash, Height, MetaProtocol, Name, URL,
+// Version, PrevHash, PrevCheckpoint, TransfersDigest and PublicKey. The schema version is a uint64, and the domain
+// and the strings are their bytes prefixed by their length as a uint64, all big endian, like getter.DigestOrdTransfers.
+// It doesn't depend on the JSON encoding, so any client can recompute it.
+func (c *Checkpoint) signingDigest() []byte {
+	h := sha256.New()
+	var buf [8]byte
+	writeUint := func(v uint64) {
+		binary.BigEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	writeString := func(s string) {
+		writeUint(uint64(len(s)))
+		h.Write([]byte(s))
+	}
+	writeString(signingDomain)
+	writeUint(uint64(c.SchemaVersion))
+	for _, field := range []string{
+		c.Commitment, c.Hash, c.Height, c.MetaProtocol, c.Name, c.URL, c.Version,
+		c.PrevHash, c.PrevCheckpoint, c.TransfersDigest, c.PublicKey,
+	} {
+		writeString(field)
+	}
+	return h.Sum(nil)
+}
+
+// Sign sets the public key of the checkpoint and signs it with ECDSA, the signature is DER encoded in hex.
+func Sign(c *Checkpoint, key *btcec.PrivateKey) {
+	c.PublicKey = PublicKeyHex(key)
+	c.Signature = hex.EncodeToString(ecdsa.Sign(key, c.signingDigest()).Serialize())
+}
+
+// Verify checks the signature of the checkpoint against its public key, and the public key against publicKey
+// unless it is empty. Light clients should pin the public keys of the indexers they trust.
+func Verify(c *Checkpoint, publicKey string) error {
+	if c.Signature == "" || c.PublicKey == "" {
+		return ErrUnsigned
+	}
+	if publicKey != "" && !strings.EqualFold(publicKey, c.PublicKey) {
+		return fmt.Errorf("%w: %s, expected %s", ErrUnexpectedKey, c.PublicKey, publicKey)
+	}
+	rawKey, err := hex.DecodeString(c.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: malformed public key", ErrInvalidSignature)
+	}
+	pubKey, err := btcec.ParsePubKey(rawKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	rawSignature, err := hex.DecodeString(c.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	signature, err := ecdsa.ParseDERSignature(rawSignature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !signature.Verify(c.signingDigest(), pubKey) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
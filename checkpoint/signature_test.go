@@ -0,0 +1,97 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys", "indexer.key")
+	if err := SaveKey(path, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveKey(path, key); err == nil {
+		t.Fatal("overwrote the existing key")
+	}
+	loaded, err := LoadKey(path)
+	if err != nil || PublicKeyHex(loaded) != PublicKeyHex(key) {
+		t.Fatal("unexpected loaded key", err)
+	}
+
+	c := NewCheckpoint(&IndexerIdentification{Name: "test", MetaProtocol: "brc-20"}, 780000, "hash", "commitment")
+	if err := Verify(&c, ""); !errors.Is(err, ErrUnsigned) {
+		t.Fatal("accepted an unsigned checkpoint", err)
+	}
+	Sign(&c, loaded)
+	if err := Verify(&c, PublicKeyHex(key)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The signature survives the JSON round trip of the light clients.
+	content, err := json.Marshal(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received Checkpoint
+	if err := json.Unmarshal(content, &received); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(&received, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := received
+	tampered.Commitment = "forged"
+	if err := Verify(&tampered, ""); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatal("accepted a tampered checkpoint", err)
+	}
+	other, _ := GenerateKey()
+	if err := Verify(&received, PublicKeyHex(other)); !errors.Is(err, ErrUnexpectedKey) {
+		t.Fatal("accepted a checkpoint of another indexer", err)
+	}
+	// Re-signing by another key is detected by pinning the key.
+	resigned := received
+	Sign(&resigned, other)
+	if err := Verify(&resigned, PublicKeyHex(key)); !errors.Is(err, ErrUnexpectedKey) {
+		t.Fatal("accepted a checkpoint re-signed by another key", err)
+	}
+	swapped := received
+	swapped.PublicKey = PublicKeyHex(other)
+	if err := Verify(&swapped, ""); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatal("accepted a signature by another key", err)
+	}
+}
+
+// The signing digest follows the documented canonical encoding, not the JSON of the checkpoint.
+func TestSigningDigest(t *testing.T) {
+	c := Checkpoint{
+		SchemaVersion:   2,
+		Commitment:      "commitment",
+		Hash:            "hash",
+		Height:          "780000",
+		MetaProtocol:    "brc-20",
+		Name:            "test",
+		URL:             "https://indexer.example",
+		Version:         "v1.0.0",
+		PrevHash:        "prevhash",
+		PrevCheckpoint:  "prevdigest",
+		TransfersDigest: "00",
+		PublicKey:       "02pub",
+		Signature:       "ignored",
+	}
+	if digest := c.Digest(); digest != "3809a95765deadfba7064d7bce97321d73cfadace910c3ca8457298913165916" {
+		t.Fatal("unexpected digest", digest)
+	}
+	// The fields are length prefixed, moving a byte across a field boundary changes the digest.
+	shifted := c
+	shifted.Name, shifted.URL = "tes", "thttps://indexer.example"
+	if shifted.Digest() == c.Digest() {
+		t.Fatal("the digest ignores the field boundaries")
+	}
+}
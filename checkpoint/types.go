@@ -1,14 +1,24 @@
 package checkpoint
 
+import "time"
+
 type IndexerIdentification struct {
 	URL          string
 	Name         string
 	Version      string
 	MetaProtocol string
+	// The compressed secp256k1 public key of the indexer in hex, the checkpoints are signed by its private key.
+	PublicKey string
 }
 
+// CurrentSchemaVersion is the version of the checkpoints published by this indexer, the checkpoints without
+// a schema version are of version 1, before the hash chaining.
+const CurrentSchemaVersion = 2
+
 // CheckpointFromCommitteeIndexer
 type Checkpoint struct {
+	// Version of the format of the checkpoint, see CurrentSchemaVersion
+	SchemaVersion int `json:"schemaVersion,omitempty"`
 	// Hex of the Commitment of the Verkle Tree Root
 	Commitment string `json:"commitment"`
 	// Hex of the BlockHash of the checkpoint
@@ -23,10 +33,27 @@ type Checkpoint struct {
 	URL string `json:"url"`
 	// Version number of the Modular Indexer
 	Version string `json:"version"`
+	// Hex of the BlockHash of the parent block
+	PrevHash string `json:"prevHash,omitempty"`
+	// Hex of the Digest of the checkpoint of the parent block, empty for the first checkpoint of a chain
+	PrevCheckpoint string `json:"prevCheckpoint,omitempty"`
+	// Hex of the digest of the ordered OrdTransfers of the block
+	TransfersDigest string `json:"transfersDigest,omitempty"`
+	// Hex of the compressed secp256k1 public key of the indexer
+	PublicKey string `json:"publicKey,omitempty"`
+	// Hex of the DER encoded ECDSA signature of the checkpoint by the key of the indexer
+	Signature string `json:"signature,omitempty"`
 }
 
+// UploadRecord is the upload status of a checkpoint to a destination.
 type UploadRecord struct {
-	Success bool
+	Success  bool
+	Attempts int
+	// The error of the last failed attempt.
+	LastError   string
+	LastAttempt time.Time
+	// The failed upload isn't retried before NextAttempt.
+	NextAttempt time.Time
 }
 
 type UploadHistory = map[uint]map[string]UploadRecord
@@ -0,0 +1,65 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WebhookReporter posts the checkpoints as JSON to an HTTP endpoint, any status other than 2xx is a failure.
+type WebhookReporter struct {
+	// The name of the reporter, default to "webhook".
+	ReporterName string
+	URL          string
+	// The extra headers of the requests, e.g. Authorization.
+	Headers map[string]string
+	Timeout time.Duration
+	// The client of the requests, default to http.DefaultClient.
+	Client *http.Client
+}
+
+func (r *WebhookReporter) Name() string {
+	if r.ReporterName == "" {
+		return "webhook"
+	}
+	return r.ReporterName
+}
+
+func (r *WebhookReporter) Report(c *Checkpoint) error {
+	checkpointJSON, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint to JSON: %w", err)
+	}
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(checkpointJSON))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("the webhook responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
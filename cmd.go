@@ -19,6 +19,8 @@ type RuntimeArguments struct {
 	CommitteeIndexerURL  string
 	ProtocolName         string
 	MetricAddr           string
+	BlockHashesPath      string
+	OrdTransfersPath     string
 }
 
 func NewRuntimeArguments() *RuntimeArguments {
@@ -71,10 +73,16 @@ leveraging Bitcoin's immutable and decentralized nature to provide a Turing-comp
 	rootCmd.Flags().BoolVarP(&arguments.EnableTest, "test", "t", false, "Enable this flag to hijack the blockheight to test the service")
 	rootCmd.Flags().UintVar(&arguments.TestBlockHeightLimit, "blockheight", 0, "When -test enabled, you can set TestBlockHeightLimit as a fixed value you want")
 	rootCmd.Flags().BoolVar(&arguments.EnablePprof, "pprof", false, "Enable the pprof HTTP handler (at `/debug/pprof/`)")
-	rootCmd.Flags().StringVar(&arguments.ConfigFilePath, "cfg", "config.json", "Indicate the path of config file")
+	rootCmd.PersistentFlags().StringVar(&arguments.ConfigFilePath, "cfg", "config.json", "Indicate the path of config file")
 	rootCmd.Flags().StringVarP(&arguments.CommitteeIndexerName, "name", "n", "", "Indicate the name of the committee indexer service")
 	rootCmd.Flags().StringVarP(&arguments.CommitteeIndexerURL, "url", "u", "", "Indicate the url of the committee indexer service")
 	rootCmd.Flags().StringVar(&arguments.ProtocolName, "protocol", "brc-20", "Indicate the meta protocol supported by the committee indexer")
 	rootCmd.Flags().StringVar(&arguments.MetricAddr, "metrics", "0.0.0.0:8081", "Metrics listening address")
+	rootCmd.PersistentFlags().StringVar(&arguments.BlockHashesPath, "block-hashes", "", "Indicate the file of the recorded block hashes, overriding the config file")
+	rootCmd.PersistentFlags().StringVar(&arguments.OrdTransfersPath, "ord-transfers", "", "Indicate the file of the recorded ord transfers, overriding the config file")
+
+	rootCmd.AddCommand(arguments.MakeSnapshotCmd())
+	rootCmd.AddCommand(arguments.MakeFixturesCmd())
+	rootCmd.AddCommand(arguments.MakeKeyCmd())
 	return rootCmd
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
+)
+
+func (arguments *RuntimeArguments) MakeFixturesCmd() *cobra.Command {
+	var fixturesCmd = &cobra.Command{
+		Use:   "fixtures",
+		Short: "Manage the test fixtures recorded from the getter.",
+	}
+
+	var fromHeight, toHeight uint
+	var fixturesDir string
+	var force bool
+	var recordCmd = &cobra.Command{
+		Use:   "record",
+		Short: "Record the block hashes and the ord transfers from the given height to the given height, requiring the OPI database or the ord server.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if fromHeight == 0 || toHeight < fromHeight {
+				log.Fatalf("Invalid block range: %d to %d", fromHeight, toHeight)
+			}
+			err := LoadConfig(arguments.ConfigFilePath)
+			if err != nil {
+				log.Fatalf("Failed to load config file: %v", err)
+			}
+			baseGetter, err := NewOrdGetter(arguments)
+			if err != nil {
+				log.Fatalf("Failed to initial the getter: %v", err)
+			}
+			ordGetter := NewResilientGetter(context.Background(), baseGetter)
+			latestHeight, err := ordGetter.GetLatestBlockHeight()
+			if err != nil {
+				log.Fatalf("Failed to get the latest block height: %v", err)
+			}
+			if toHeight > latestHeight {
+				log.Fatalf("The height %d is above the latest block height %d", toHeight, latestHeight)
+			}
+
+			// The files are named after the last height, as read by the test getter.
+			flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			if force {
+				flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			create := func(name string) *os.File {
+				file, err := os.OpenFile(filepath.Join(fixturesDir, fmt.Sprintf("%d-%s", toHeight, name)), flag, 0644)
+				if err != nil {
+					log.Fatalf("Failed to create the fixture: %v", err)
+				}
+				return file
+			}
+			blockHashes := create("brc20_block_hashes.csv")
+			defer blockHashes.Close()
+			ordTransfers := create("ord_transfers.csv")
+			defer ordTransfers.Close()
+			recorder, err := getter.NewRecordingGetter(ordGetter, blockHashes, ordTransfers)
+			if err != nil {
+				log.Fatalf("Failed to write the fixtures: %v", err)
+			}
+
+			// The hash of the parent block is recorded so the first block can be executed on it.
+			for i := fromHeight - 1; i <= toHeight; i++ {
+				_, err := recorder.GetBlockHash(i)
+				if err != nil {
+					log.Fatalf("Failed to get the block hash at height %d: %v", i, err)
+				}
+			}
+			blocks := NewPrefetcher(context.Background(), recorder, fromHeight, toHeight)
+			defer blocks.Close()
+			for i := fromHeight; i <= toHeight; i++ {
+				_, _, err := blocks.Next()
+				if err != nil {
+					log.Fatalf("Failed to get the ord transfers at height %d: %v", i, err)
+				}
+			}
+			err = recorder.Flush()
+			if err != nil {
+				log.Fatalf("Failed to write the fixtures: %v", err)
+			}
+			log.Printf("Succeed to record the blocks from %d to %d: %s, %s", fromHeight, toHeight, blockHashes.Name(), ordTransfers.Name())
+		},
+	}
+	recordCmd.Flags().UintVar(&fromHeight, "from", 0, "The first height to record")
+	recordCmd.Flags().UintVar(&toHeight, "to", 0, "The last height to record")
+	recordCmd.Flags().StringVar(&fixturesDir, "dir", "data", "Indicate the directory of the fixtures")
+	recordCmd.Flags().BoolVar(&force, "force", false, "Overwrite the existing fixtures")
+	_ = recordCmd.MarkFlagRequired("from")
+	_ = recordCmd.MarkFlagRequired("to")
+
+	fixturesCmd.AddCommand(recordCmd)
+	return fixturesCmd
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RiemaLabs/modular-indexer-committee/checkpoint"
+)
+
+func (arguments *RuntimeArguments) MakeKeyCmd() *cobra.Command {
+	var keyCmd = &cobra.Command{
+		Use:   "key",
+		Short: "Manage the identity key of the indexer signing the checkpoints.",
+	}
+
+	var keyFile string
+	// The path from the flag takes priority over the config file, which is optional here.
+	keyPath := func() string {
+		if keyFile != "" {
+			return keyFile
+		}
+		err := LoadConfig(arguments.ConfigFilePath)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Failed to load config file: %v", err)
+		}
+		return KeyFile()
+	}
+	keyCmd.PersistentFlags().StringVar(&keyFile, "key", "", "Indicate the key file, overriding the config file")
+
+	var force bool
+	var generateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a secp256k1 key and print its public key.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			path := keyPath()
+			if force {
+				err := os.Remove(path)
+				if err != nil && !os.IsNotExist(err) {
+					log.Fatalf("Failed to remove the existing key: %v", err)
+				}
+			}
+			key, err := checkpoint.GenerateKey()
+			if err != nil {
+				log.Fatalf("Failed to generate the key: %v", err)
+			}
+			err = checkpoint.SaveKey(path, key)
+			if os.IsExist(err) {
+				log.Fatalf("The key %s exists, use --force to replace it", path)
+			}
+			if err != nil {
+				log.Fatalf("Failed to save the key: %v", err)
+			}
+			log.Printf("Succeed to generate the key at: %s", path)
+			fmt.Println(checkpoint.PublicKeyHex(key))
+		},
+	}
+	generateCmd.Flags().BoolVar(&force, "force", false, "Replace the existing key, the checkpoints signed by it can't be attributed to the new one")
+
+	var showCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Print the public key to be pinned by the light clients.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			key, err := checkpoint.LoadKey(keyPath())
+			if err != nil {
+				log.Fatalf("Failed to load the key: %v", err)
+			}
+			fmt.Println(checkpoint.PublicKeyHex(key))
+		},
+	}
+
+	keyCmd.AddCommand(generateCmd, showCmd)
+	return keyCmd
+}
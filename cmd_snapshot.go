@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord"
+	"github.com/RiemaLabs/modular-indexer-committee/ord/stateless"
+)
+
+func (arguments *RuntimeArguments) MakeSnapshotCmd() *cobra.Command {
+	var snapshotDir string
+
+	// Load the config and build the snapshot manager, the directory from the flag takes priority.
+	loadSnapshots := func() *stateless.SnapshotManager {
+		err := LoadConfig(arguments.ConfigFilePath)
+		if err != nil {
+			log.Fatalf("Failed to load config file: %v", err)
+		}
+		snapshots := NewSnapshotManager()
+		if snapshotDir != "" {
+			snapshots.Dir = snapshotDir
+		}
+		return snapshots
+	}
+
+	var snapshotCmd = &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manage the snapshots of the state in the cache directory.",
+	}
+	snapshotCmd.PersistentFlags().StringVar(&snapshotDir, "dir", "", "Indicate the snapshot directory, overriding the config file")
+
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List all snapshots with their header records.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			snapshots := loadSnapshots()
+			infos, err := snapshots.List()
+			if err != nil {
+				log.Fatalf("Failed to list the snapshots: %v", err)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "HEIGHT\tSIZE\tVERSION\tHASH\tCOMMITMENT\tKEYS\tSTATUS")
+			for _, info := range infos {
+				if info.Err != nil {
+					fmt.Fprintf(w, "%d\t%d\t-\t-\t-\t-\t%v\n", info.Height, info.Size, info.Err)
+					continue
+				}
+				sh := info.Header
+				fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%d\tok\n", info.Height, info.Size, sh.Version, sh.Hash, sh.Commitment, sh.KeyCount)
+			}
+			_ = w.Flush()
+		},
+	}
+
+	var createHeight uint
+	var createCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create a snapshot at the given height from the newest snapshot below it, requiring the OPI database, the ord server or the recorded files.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			snapshots := loadSnapshots()
+			baseGetter, err := NewOrdGetter(arguments)
+			if err != nil {
+				log.Fatalf("Failed to initial the getter: %v", err)
+			}
+			ordGetter := NewResilientGetter(context.Background(), baseGetter)
+			height := createHeight
+			if height == 0 {
+				latestHeight, err := ordGetter.GetLatestBlockHeight()
+				if err != nil {
+					log.Fatalf("Failed to get the latest block height: %v", err)
+				}
+				height = latestHeight - ord.BitcoinConfirmations
+			}
+
+			store, err := NewStateStore()
+			if err != nil {
+				log.Fatalf("Failed to open the state store: %v", err)
+			}
+			defer store.Close()
+			header, err := snapshots.Load(ordGetter, store, height)
+			if err != nil {
+				log.Fatalf("Failed to load the snapshot: %v", err)
+			}
+			if header == nil {
+				header, err = snapshots.LoadHeader(ordGetter, store, false, stateless.BRC20StartHeight-1, height)
+				if err != nil {
+					log.Fatalf("Failed to initial the header: %v", err)
+				}
+			}
+			log.Printf("Create the snapshot at height %d from height %d", height, header.Height)
+			blocks := NewPrefetcher(context.Background(), ordGetter, header.Height+1, height)
+			defer blocks.Close()
+			for i := header.Height + 1; i <= height; i++ {
+				ordTransfer, hash, err := blocks.Next()
+				if err != nil {
+					log.Fatalf("Failed to get the ord transfers at height %d: %v", i, err)
+				}
+				stateless.Exec(header, ordTransfer, i)
+				err = header.PagingWithHash(hash, stateless.NodeResolveFn)
+				if err != nil {
+					log.Fatalf("Failed to page the header at height %d: %v", i, err)
+				}
+				if i%1000 == 0 {
+					log.Printf("Blocks: %d / %d \n", i, height)
+				}
+			}
+			err = snapshots.StoreHeader(ordGetter, header)
+			if err != nil {
+				log.Fatalf("Failed to store the snapshot: %v", err)
+			}
+			log.Printf("Succeed to create the snapshot: %s", snapshots.Path(header.Height))
+		},
+	}
+	createCmd.Flags().UintVar(&createHeight, "height", 0, "The height of the snapshot, default to the latest confirmed height")
+
+	var verifyCmd = &cobra.Command{
+		Use:   "verify [height...]",
+		Short: "Verify the checksum, key count and commitment of the snapshots, all snapshots by default.",
+		Run: func(cmd *cobra.Command, args []string) {
+			snapshots := loadSnapshots()
+			heights := make([]uint, 0, len(args))
+			for _, arg := range args {
+				height, err := strconv.ParseUint(arg, 10, 64)
+				if err != nil {
+					log.Fatalf("Invalid height: %s", arg)
+				}
+				heights = append(heights, uint(height))
+			}
+			if len(heights) == 0 {
+				var err error
+				heights, err = snapshots.Heights()
+				if err != nil {
+					log.Fatalf("Failed to list the snapshots: %v", err)
+				}
+			}
+			failed := 0
+			for _, height := range heights {
+				sh, err := snapshots.Verify(height, stateless.NewMemoryStore())
+				if err != nil {
+					failed++
+					log.Printf("Snapshot at height %d is invalid: %v", height, err)
+					continue
+				}
+				log.Printf("Snapshot at height %d is valid, hash: %s, commitment: %s", height, sh.Hash, sh.Commitment)
+			}
+			if failed != 0 {
+				log.Fatalf("%d of %d snapshots are invalid", failed, len(heights))
+			}
+		},
+	}
+
+	var pruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the snapshots rejected by the retention policy.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			snapshots := loadSnapshots()
+			removed, err := snapshots.Prune()
+			if err != nil {
+				log.Fatalf("Failed to prune the snapshots: %v", err)
+			}
+			for _, height := range removed {
+				log.Printf("Removed the snapshot at height %d", height)
+			}
+			log.Printf("Pruned %d snapshots", len(removed))
+		},
+	}
+
+	snapshotCmd.AddCommand(listCmd, createCmd, verifyCmd, pruneCmd)
+	return snapshotCmd
+}
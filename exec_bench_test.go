@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord"
+	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
+	"github.com/RiemaLabs/modular-indexer-committee/ord/stateless"
+)
+
+// benchTransfers generates the blocks from the BRC-20 start height: the first one deploys the ticks, then each
+// block mints to its wallets, and each wallet inscribes a transfer and sends it to the next wallet in the next block.
+func benchTransfers(blocks uint, wallets int) map[uint][]getter.OrdTransfer {
+	ticks := []string{"ordi", "sats", "meme"}
+	var transferID, inscriptions uint
+	transfer := func(blockHeight uint, inscriptionID string, oldSatpoint string, wallet int, content string) getter.OrdTransfer {
+		transferID++
+		return getter.OrdTransfer{
+			ID:            transferID,
+			InscriptionID: inscriptionID,
+			BlockHeight:   blockHeight,
+			OldSatpoint:   oldSatpoint,
+			NewSatpoint:   fmt.Sprintf("%064x:0:0", transferID),
+			NewPkscript:   ord.Pkscript(fmt.Sprintf("0014%040x", wallet)),
+			NewWallet:     ord.Wallet(fmt.Sprintf("bc1q%038x", wallet)),
+			Content:       []byte(content),
+			ContentType:   "text/plain;charset=utf-8",
+		}
+	}
+	inscribe := func(blockHeight uint, wallet int, content string) getter.OrdTransfer {
+		inscriptions++
+		return transfer(blockHeight, fmt.Sprintf("%064xi0", inscriptions), "", wallet, content)
+	}
+
+	first := stateless.BRC20StartHeight
+	transfers := make(map[uint][]getter.OrdTransfer, blocks)
+	for _, tick := range ticks {
+		deploy := fmt.Sprintf(`{"p":"brc-20","op":"deploy","tick":"%s","max":"21000000000","lim":"1000"}`, tick)
+		transfers[first] = append(transfers[first], inscribe(first, 0, deploy))
+	}
+	// The transfer inscriptions of the previous block, indexed by the wallet holding them.
+	var pending []getter.OrdTransfer
+	for blockHeight := first + 1; blockHeight < first+blocks; blockHeight++ {
+		ordTransfers := make([]getter.OrdTransfer, 0, 3*wallets)
+		for wallet, sent := range pending {
+			ordTransfers = append(ordTransfers, transfer(blockHeight, sent.InscriptionID, sent.NewSatpoint, (wallet+1)%wallets, string(sent.Content)))
+		}
+		pending = pending[:0]
+		for wallet := range wallets {
+			tick := ticks[wallet%len(ticks)]
+			mint := inscribe(blockHeight, wallet, fmt.Sprintf(`{"p":"brc-20","op":"mint","tick":"%s","amt":"1000"}`, tick))
+			inscribed := inscribe(blockHeight, wallet, fmt.Sprintf(`{"p":"brc-20","op":"transfer","tick":"%s","amt":"10"}`, tick))
+			ordTransfers = append(ordTransfers, mint, inscribed)
+			pending = append(pending, inscribed)
+		}
+		transfers[blockHeight] = ordTransfers
+	}
+	return transfers
+}
+
+// BenchmarkExec executes blocks of generated mints and transfers from the BRC-20 start height.
+func BenchmarkExec(b *testing.B) {
+	const blocks = 20
+	transfers := benchTransfers(blocks, 200)
+
+	b.ResetTimer()
+	for range b.N {
+		header := &stateless.Header{
+			Root:           verkle.New(),
+			KV:             stateless.NewMemoryStore(),
+			Height:         stateless.BRC20StartHeight - 1,
+			Access:         stateless.AccessList{},
+			IntermediateKV: stateless.KeyValueMap{},
+		}
+		for i := stateless.BRC20StartHeight; i < stateless.BRC20StartHeight+blocks; i++ {
+			stateless.Exec(header, transfers[i], i)
+			if err := header.PagingWithHash(fmt.Sprintf("%064x", i), stateless.NodeResolveFn); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"encoding/json"
+	"os"
+)
+
 type Config struct {
 	Database struct {
 		Host     string `json:"host"`
@@ -8,6 +13,15 @@ type Config struct {
 		DBname   string `json:"dbname"`
 		Port     string `json:"port"`
 	} `json:"database"`
+	Ord struct {
+		// The ord server used instead of the OPI database if it is set, e.g. http://127.0.0.1:80.
+		URL string `json:"url"`
+	} `json:"ord"`
+	File struct {
+		// The recorded OPI data used instead of the OPI database if both are set, CSV or JSON lines optionally gzipped.
+		BlockHashes string `json:"blockHashes"`
+		Transfers   string `json:"transfers"`
+	} `json:"file"`
 	Report struct {
 		Method  string `json:"method"`
 		Timeout int    `json:"timeout"`
@@ -23,12 +37,93 @@ type Config struct {
 			GasCoupon   string `json:"gasCoupon"`
 			PrivateKey  string `json:"privateKey"`
 		} `json:"da"`
+		// The reporters of the checkpoints, the single reporter of Method is used if it is empty.
+		Reporters []ReporterConfig `json:"reporters"`
+		Outbox    struct {
+			Path string `json:"path"`
+			// The backoff of the failed uploads in milliseconds, it doubles after each failure up to MaxBackoff.
+			MinBackoff uint `json:"minBackoff"`
+			MaxBackoff uint `json:"maxBackoff"`
+		} `json:"outbox"`
 	} `json:"report"`
+	State struct {
+		Store     string `json:"store"`
+		Path      string `json:"path"`
+		NodeStore struct {
+			Enable    bool   `json:"enable"`
+			Path      string `json:"path"`
+			CacheSize int    `json:"cacheSize"`
+		} `json:"nodeStore"`
+		Journal struct {
+			Enable bool   `json:"enable"`
+			Path   string `json:"path"`
+		} `json:"journal"`
+		History struct {
+			// The memory budget of the cached past states in MiB.
+			CacheMemory uint64 `json:"cacheMemory"`
+		} `json:"history"`
+	} `json:"state"`
+	Snapshot struct {
+		Dir       string `json:"dir"`
+		Interval  uint   `json:"interval"`
+		Retention struct {
+			KeepLast        int  `json:"keepLast"`
+			KeepEvery       uint `json:"keepEvery"`
+			KeepCheckpoints bool `json:"keepCheckpoints"`
+		} `json:"retention"`
+	} `json:"snapshot"`
+	Reorg struct {
+		Depth uint `json:"depth"`
+	} `json:"reorg"`
+	Getter struct {
+		// The durations are in milliseconds.
+		Timeout          uint `json:"timeout"`
+		Attempts         int  `json:"attempts"`
+		MinBackoff       uint `json:"minBackoff"`
+		MaxBackoff       uint `json:"maxBackoff"`
+		FailureThreshold int  `json:"failureThreshold"`
+		OpenDuration     uint `json:"openDuration"`
+	} `json:"getter"`
+	Listen struct {
+		// The interval of polling the OPI database in milliseconds.
+		Interval uint `json:"interval"`
+		Notify   struct {
+			Enable  bool   `json:"enable"`
+			Channel string `json:"channel"`
+		} `json:"notify"`
+	} `json:"listen"`
+	Catchup struct {
+		BatchSize uint `json:"batchSize"`
+		Prefetch  int  `json:"prefetch"`
+	} `json:"catchup"`
 	Service struct {
 		Name         string `json:"name"`
 		URL          string `json:"url"`
 		MetaProtocol string `json:"metaProtocol"`
+		// The file of the secp256k1 key signing the checkpoints, default to .cache/indexer.key.
+		KeyFile string `json:"keyFile"`
 	} `json:"service"`
 }
 
+// ReporterConfig configures a reporter of the checkpoints, the S3 and DA reporters use the s3 and da sections.
+type ReporterConfig struct {
+	// One of S3, DA, file and webhook.
+	Type string `json:"type"`
+	// The name of the reporter in the outbox, default to the type. It is required for several reporters of a type.
+	Name string `json:"name,omitempty"`
+	// The directory of the file reporter.
+	Dir string `json:"dir,omitempty"`
+	// The endpoint and the extra headers of the webhook reporter.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
 var GlobalConfig Config
+
+func LoadConfig(path string) error {
+	configFile, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(configFile, &GlobalConfig)
+}
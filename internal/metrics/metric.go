@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
@@ -18,8 +19,13 @@ const (
 	StageServing
 	StageUpdating
 	StageReorg
+	StageStopping
+	StageStale
 )
 
+// The time given to the in-flight scrapes on shutdown.
+const shutdownTimeout = 5 * time.Second
+
 func fqn(name string) string {
 	return prometheus.BuildFQName("nubit", "modular_committee", name)
 }
@@ -33,6 +39,14 @@ var (
 		[]string{"version"},
 	)
 
+	OPIVersion = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: fqn("opi_version"),
+			Help: "Version of the OPI indexer, the value is its database version",
+		},
+		[]string{"version"},
+	)
+
 	Stage = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: fqn("stage"),
 		Help: "Service stage (e.g. initializing, catchup)",
@@ -47,11 +61,55 @@ var (
 		[]string{"op"},
 	)
 
+	DBQueryRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: fqn("dbquery_retries"),
+			Help: "Number of retried database queries",
+		},
+		[]string{"op"},
+	)
+
+	CheckpointUploads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: fqn("checkpoint_uploads"),
+			Help: "Number of checkpoint upload attempts",
+		},
+		[]string{"destination", "result"},
+	)
+
+	CheckpointPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: fqn("checkpoint_pending"),
+		Help: "Number of checkpoint uploads waiting for a retry",
+	})
+
 	CurrentHeight = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: fqn("current_height"),
 		Help: "Current height during catchup or serving",
 	})
 
+	TreeBuildDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    fqn("tree_build_duration"),
+			Help:    "Duration of rebuilding the verkle tree from the key values",
+			Buckets: []float64{0.1, 0.5, 1, 5, 15, 60, 300},
+		},
+		[]string{"op"},
+	)
+
+	StartupDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: fqn("startup_duration"),
+		Help: "Duration in seconds of loading the state on startup",
+	})
+
+	ReorgDepth = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    fqn("reorg_depth"),
+			Help:    "Depth of the detected reorgs in blocks, deep reorgs are beyond the history of the queue",
+			Buckets: []float64{1, 2, 3, 6, 12, 24, 100},
+		},
+		[]string{"kind"},
+	)
+
 	HttpDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    fqn("http_duration"),
@@ -66,6 +124,10 @@ func ObserveDBQuery(op string, started time.Time) {
 	DBQueryDuration.WithLabelValues(op).Observe(time.Since(started).Seconds())
 }
 
+func ObserveTreeBuild(op string, started time.Time) {
+	TreeBuildDuration.WithLabelValues(op).Observe(time.Since(started).Seconds())
+}
+
 func HTTP(c *gin.Context) {
 	started := time.Now()
 
@@ -81,17 +143,34 @@ func HTTP(c *gin.Context) {
 func init() {
 	prometheus.MustRegister(
 		Version,
+		OPIVersion,
 		Stage,
 		DBQueryDuration,
+		DBQueryRetries,
+		CheckpointUploads,
+		CheckpointPending,
 		CurrentHeight,
+		TreeBuildDuration,
+		StartupDuration,
+		ReorgDepth,
 		HttpDuration,
 	)
 }
 
-func ListenAndServe(addr string) {
+// ListenAndServe serves the metrics until ctx is done, then shuts the server down gracefully.
+func ListenAndServe(ctx context.Context, addr string) {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
-	if err := (&http.Server{Addr: addr, Handler: mux}).ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down the metrics server: %v", err)
+		}
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
 }
@@ -2,11 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
@@ -14,6 +17,8 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+
 	"github.com/RiemaLabs/modular-indexer-committee/apis"
 	"github.com/RiemaLabs/modular-indexer-committee/checkpoint"
 	"github.com/RiemaLabs/modular-indexer-committee/internal/metrics"
@@ -27,47 +32,471 @@ var (
 	gitHash = "unknown"
 )
 
-func CatchupStage(ordGetter getter.OrdGetter, arguments *RuntimeArguments, initHeight uint, latestHeight uint) (*stateless.Queue, error) {
+func StateStorePath() string {
+	path := GlobalConfig.State.Path
+	if path == "" {
+		path = ".cache/state.db"
+	}
+	return path
+}
+
+func NewStateStore() (stateless.StateStore, error) {
+	switch GlobalConfig.State.Store {
+	case "", "memory":
+		return stateless.NewMemoryStore(), nil
+	case "bolt":
+		path := StateStorePath()
+		log.Printf("Use the bolt state store at: %s", path)
+		return stateless.NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown state store: %s", GlobalConfig.State.Store)
+	}
+}
+
+// NewRecoveryStateStore opens an empty state store next to the one in use, the state is rebuilt into it while the old one is served.
+// Once the old store is closed, promote moves the new one to the path of the old one.
+func NewRecoveryStateStore() (store stateless.StateStore, promote func() error, err error) {
+	switch GlobalConfig.State.Store {
+	case "", "memory":
+		return stateless.NewMemoryStore(), func() error { return nil }, nil
+	case "bolt":
+		path := StateStorePath()
+		recoveryPath := path + ".recovery"
+		// Drop the leftover of an interrupted recovery.
+		err := os.Remove(recoveryPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+		log.Printf("Rebuild the state in the bolt state store at: %s", recoveryPath)
+		store, err := stateless.NewBoltStore(recoveryPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, func() error { return os.Rename(recoveryPath, path) }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown state store: %s", GlobalConfig.State.Store)
+	}
+}
+
+func NewNodeStore() (*stateless.NodeStore, error) {
+	cfg := GlobalConfig.State.NodeStore
+	path := cfg.Path
+	if path == "" {
+		path = ".cache/nodes.db"
+	}
+	cacheSize := cfg.CacheSize
+	if cacheSize <= 0 {
+		cacheSize = 1 << 16
+	}
+	log.Printf("Use the verkle node store at: %s, cache size: %d", path, cacheSize)
+	return stateless.NewNodeStore(path, cacheSize)
+}
+
+func NewJournal() (*stateless.Journal, error) {
+	path := GlobalConfig.State.Journal.Path
+	if path == "" {
+		path = ".cache/journal.db"
+	}
+	log.Printf("Use the state journal at: %s", path)
+	return stateless.NewJournal(path)
+}
+
+func OutboxPath() string {
+	path := GlobalConfig.Report.Outbox.Path
+	if path == "" {
+		path = ".cache/outbox.db"
+	}
+	return path
+}
+
+func NewOutbox() (*checkpoint.Outbox, error) {
+	cfg := GlobalConfig.Report.Outbox
+	path := OutboxPath()
+	minBackoff := time.Duration(cfg.MinBackoff) * time.Millisecond
+	if minBackoff <= 0 {
+		minBackoff = 10 * time.Second
+	}
+	maxBackoff := time.Duration(cfg.MaxBackoff) * time.Millisecond
+	if maxBackoff <= 0 {
+		maxBackoff = 10 * time.Minute
+	}
+	log.Printf("Use the checkpoint outbox at: %s", path)
+	return checkpoint.NewOutbox(path, minBackoff, maxBackoff)
+}
+
+// checkpointOutbox is the outbox opened by the service stage, the pruning of the snapshots reads it while it is open.
+var checkpointOutbox *checkpoint.Outbox
+
+// PublishedHeights returns the heights of the published checkpoints, from the outbox of the service stage if it
+// is open, or from the outbox file otherwise, e.g. for the snapshot subcommands.
+func PublishedHeights() ([]uint, error) {
+	if checkpointOutbox != nil {
+		return checkpointOutbox.Published()
+	}
+	if _, err := os.Stat(OutboxPath()); os.IsNotExist(err) {
+		return []uint{}, nil
+	}
+	outbox, err := NewOutbox()
+	if err != nil {
+		return nil, err
+	}
+	defer outbox.Close()
+	return outbox.Published()
+}
+
+// KeyFile returns the path of the identity key of the indexer signing the checkpoints.
+func KeyFile() string {
+	path := GlobalConfig.Service.KeyFile
+	if path == "" {
+		path = ".cache/indexer.key"
+	}
+	return path
+}
+
+// LoadIndexerKey loads the identity key of the indexer, it is generated by the `key generate` subcommand.
+func LoadIndexerKey() (*btcec.PrivateKey, error) {
+	path := KeyFile()
+	key, err := checkpoint.LoadKey(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no indexer key at %s, generate one by `key generate`: %w", path, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Sign the checkpoints by the indexer key: %s", checkpoint.PublicKeyHex(key))
+	return key, nil
+}
+
+// ReporterConfigs returns the configured reporters, the legacy `method` stands for a single reporter.
+func ReporterConfigs() []ReporterConfig {
+	if len(GlobalConfig.Report.Reporters) > 0 {
+		return GlobalConfig.Report.Reporters
+	}
+	return []ReporterConfig{{Type: GlobalConfig.Report.Method}}
+}
+
+// NewReporters builds the reporters of the checkpoints, the names of the reporters must be unique.
+func NewReporters() ([]checkpoint.Reporter, error) {
+	timeout := time.Duration(GlobalConfig.Report.Timeout) * time.Millisecond
+	reporters := make([]checkpoint.Reporter, 0)
+	names := make(map[string]bool)
+	for _, cfg := range ReporterConfigs() {
+		var reporter checkpoint.Reporter
+		switch cfg.Type {
+		case "S3":
+			s3cfg := GlobalConfig.Report.S3
+			reporter = &checkpoint.S3Reporter{
+				ReporterName: cfg.Name,
+				AccessKey:    s3cfg.AccessKey,
+				SecretKey:    s3cfg.SecretKey,
+				Region:       s3cfg.Region,
+				Bucket:       s3cfg.Bucket,
+				Timeout:      timeout,
+			}
+		case "DA":
+			dacfg := GlobalConfig.Report.Da
+			reporter = &checkpoint.DAReporter{
+				ReporterName: cfg.Name,
+				PrivateKey:   dacfg.PrivateKey,
+				GasCoupon:    dacfg.GasCoupon,
+				NamespaceID:  dacfg.NamespaceID,
+				Network:      dacfg.Network,
+				Timeout:      timeout,
+			}
+		case "file":
+			dir := cfg.Dir
+			if dir == "" {
+				dir = ".cache/checkpoints"
+			}
+			reporter = &checkpoint.FileReporter{
+				ReporterName: cfg.Name,
+				Dir:          dir,
+			}
+		case "webhook":
+			if cfg.URL == "" {
+				return nil, errors.New("the url of the webhook reporter is empty")
+			}
+			reporter = &checkpoint.WebhookReporter{
+				ReporterName: cfg.Name,
+				URL:          cfg.URL,
+				Headers:      cfg.Headers,
+				Timeout:      timeout,
+			}
+		default:
+			return nil, fmt.Errorf("unknown reporter type: %q", cfg.Type)
+		}
+		if names[reporter.Name()] {
+			return nil, fmt.Errorf("duplicated reporter name: %s", reporter.Name())
+		}
+		names[reporter.Name()] = true
+		reporters = append(reporters, reporter)
+	}
+	return reporters, nil
+}
+
+// ReportsToDA reports whether a reporter publishes to the DA layer, which requires a namespace.
+func ReportsToDA() bool {
+	for _, cfg := range ReporterConfigs() {
+		if cfg.Type == "DA" {
+			return true
+		}
+	}
+	return false
+}
+
+// NewIndexerIdentification identifies the committee indexer in the checkpoints, the flags take priority over the config.
+func NewIndexerIdentification(arguments *RuntimeArguments) checkpoint.IndexerIdentification {
+	committeeIndexerName := GlobalConfig.Service.Name
+	if arguments.CommitteeIndexerName != "" {
+		committeeIndexerName = arguments.CommitteeIndexerName
+	}
+	serviceURL := GlobalConfig.Service.URL
+	if arguments.CommitteeIndexerURL != "" {
+		serviceURL = arguments.CommitteeIndexerURL
+	}
+	metaProtocol := GlobalConfig.Service.MetaProtocol
+	if arguments.ProtocolName != "" {
+		metaProtocol = arguments.ProtocolName
+	}
+	return checkpoint.IndexerIdentification{
+		URL:          serviceURL,
+		Name:         committeeIndexerName,
+		Version:      version,
+		MetaProtocol: metaProtocol,
+	}
+}
+
+func NewHistory() (*stateless.History, error) {
+	cacheMemory := GlobalConfig.State.History.CacheMemory
+	if cacheMemory == 0 {
+		cacheMemory = 4096
+	}
+	return stateless.NewHistory(NewSnapshotManager(), cacheMemory<<20)
+}
+
+// ReorgDepth returns the number of the latest blocks kept in the queue for the reorg recovery.
+func ReorgDepth() uint {
+	if GlobalConfig.Reorg.Depth == 0 {
+		return ord.BitcoinConfirmations
+	}
+	return GlobalConfig.Reorg.Depth
+}
+
+// NewPrefetcher fetches the blocks from fromHeight to toHeight ahead of the execution during the catchup.
+func NewPrefetcher(ctx context.Context, ordGetter getter.OrdGetter, fromHeight uint, toHeight uint) *getter.Prefetcher {
+	batchSize := GlobalConfig.Catchup.BatchSize
+	if batchSize == 0 {
+		batchSize = 100
+	}
+	prefetch := GlobalConfig.Catchup.Prefetch
+	if prefetch <= 0 {
+		prefetch = 4
+	}
+	return getter.NewPrefetcher(ctx, ordGetter, fromHeight, toHeight, batchSize, prefetch)
+}
+
+// CheckOPISchema refuses to start if the OPI database isn't compatible with the queries of the getter.
+func CheckOPISchema(opiGetter *getter.OPIOrdGetter) {
+	report, err := opiGetter.CheckSchema()
+	if err != nil {
+		log.Fatalf("Failed to check the schema of the OPI database: %v", err)
+	}
+	if !report.Compatible() {
+		log.Fatalf("The OPI database is incompatible, please run OPI %s:\n%s", getter.SupportedOPIVersion, report)
+	}
+	log.Printf("Checked the schema of the OPI database, OPI indexer version: %s", report.IndexerVersion)
+}
+
+// NewOrdGetter reads the blocks from the recorded files or the ord server if they are configured,
+// otherwise from the OPI database. The paths of the files from the flags take priority.
+func NewOrdGetter(arguments *RuntimeArguments) (getter.OrdGetter, error) {
+	blockHashesPath, ordTransfersPath := GlobalConfig.File.BlockHashes, GlobalConfig.File.Transfers
+	if arguments.BlockHashesPath != "" {
+		blockHashesPath = arguments.BlockHashesPath
+	}
+	if arguments.OrdTransfersPath != "" {
+		ordTransfersPath = arguments.OrdTransfersPath
+	}
+	if blockHashesPath != "" || ordTransfersPath != "" {
+		if blockHashesPath == "" || ordTransfersPath == "" {
+			return nil, errors.New("both the block hashes and the ord transfers files are required")
+		}
+		log.Printf("Use the recorded files as the getter: %s, %s", blockHashesPath, ordTransfersPath)
+		return getter.NewFileOrdGetter(blockHashesPath, ordTransfersPath)
+	}
+	if GlobalConfig.Ord.URL != "" {
+		log.Printf("Use the ord server as the getter: %s", GlobalConfig.Ord.URL)
+		return getter.NewOrdHTTPGetter(GlobalConfig.Ord.URL, &http.Client{})
+	}
+	gd := getter.DatabaseConfig(GlobalConfig.Database)
+	opiGetter, err := getter.NewOPIOrdGetter(&gd)
+	if err != nil {
+		return nil, err
+	}
+	CheckOPISchema(opiGetter)
+	return opiGetter, nil
+}
+
+// NewResilientGetter retries the failed queries of the getter and stops querying a failing database for a while.
+func NewResilientGetter(ctx context.Context, ordGetter getter.OrdGetter) *getter.ResilientGetter {
+	cfg := GlobalConfig.Getter
+	return getter.NewResilientGetter(ctx, ordGetter, getter.ResilienceConfig{
+		QueryTimeout:     time.Duration(cfg.Timeout) * time.Millisecond,
+		MaxAttempts:      cfg.Attempts,
+		MinBackoff:       time.Duration(cfg.MinBackoff) * time.Millisecond,
+		MaxBackoff:       time.Duration(cfg.MaxBackoff) * time.Millisecond,
+		FailureThreshold: cfg.FailureThreshold,
+		OpenDuration:     time.Duration(cfg.OpenDuration) * time.Millisecond,
+	})
+}
+
+// PollInterval returns the interval of polling the new blocks, it is the fallback if the notification is enabled.
+func PollInterval() time.Duration {
+	if GlobalConfig.Listen.Interval == 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(GlobalConfig.Listen.Interval) * time.Millisecond
+}
+
+// SubscribeNewBlocks subscribes to the notification of the new blocks if it is enabled and supported by the getter.
+// It returns a nil channel otherwise, which is never signaled.
+func SubscribeNewBlocks(ctx context.Context, ordGetter getter.OrdGetter) <-chan struct{} {
+	cfg := GlobalConfig.Listen.Notify
+	if !cfg.Enable {
+		return nil
+	}
+	notifier, ok := ordGetter.(getter.BlockNotifier)
+	if !ok {
+		log.Printf("The getter doesn't support the notification of new blocks, fall back to polling")
+		return nil
+	}
+	channel := cfg.Channel
+	if channel == "" {
+		channel = getter.NewBlockChannel
+	}
+	newBlocks, err := notifier.SubscribeNewBlocks(ctx, channel)
+	if err != nil {
+		log.Printf("Failed to subscribe to the new blocks, fall back to polling: %v", err)
+		return nil
+	}
+	log.Printf("Subscribed to the new blocks on the channel: %s", channel)
+	return newBlocks
+}
+
+func NewSnapshotManager() *stateless.SnapshotManager {
+	cfg := GlobalConfig.Snapshot
+	dir := cfg.Dir
+	if dir == "" {
+		dir = ".cache"
+	}
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = 1000
+	}
+	retention := stateless.RetentionPolicy{
+		KeepLast:  cfg.Retention.KeepLast,
+		KeepEvery: cfg.Retention.KeepEvery,
+	}
+	if cfg.Retention.KeepCheckpoints {
+		retention.KeepHeights = PublishedHeights
+	}
+	if retention.KeepLast <= 0 && retention.KeepEvery == 0 {
+		retention.KeepLast = 3
+	}
+	return stateless.NewSnapshotManager(dir, interval, retention)
+}
+
+func CatchupStage(ctx context.Context, ordGetter getter.OrdGetter, arguments *RuntimeArguments, initHeight uint, latestHeight uint) (*stateless.Queue, error) {
 	metrics.Stage.Set(metrics.StageCatchup)
 
+	store, err := NewStateStore()
+	if err != nil {
+		return nil, err
+	}
+	if GlobalConfig.State.NodeStore.Enable {
+		nodeStore, err := NewNodeStore()
+		if err != nil {
+			return nil, err
+		}
+		stateless.UseNodeStore(nodeStore)
+	}
+	if GlobalConfig.State.Journal.Enable {
+		journal, err := NewJournal()
+		if err != nil {
+			return nil, err
+		}
+		stateless.UseJournal(journal)
+	}
+	catchupHeight := latestHeight - ReorgDepth()
+	return catchup(ctx, ordGetter, arguments, NewSnapshotManager(), store, initHeight, catchupHeight, latestHeight)
+}
+
+// catchup loads the newest valid snapshot not above loadHeight into the store and executes the blocks up to the start of the queue.
+// If ctx is done in the middle, the executed state is stored as a snapshot and ctx.Err() is returned.
+func catchup(ctx context.Context, ordGetter getter.OrdGetter, arguments *RuntimeArguments, snapshots *stateless.SnapshotManager, store stateless.StateStore, initHeight uint, loadHeight uint, latestHeight uint) (*stateless.Queue, error) {
+	depth := ReorgDepth()
+
+	catchupHeight := latestHeight - depth
+	loadHeight = min(loadHeight, catchupHeight)
+
 	// Fetch the latest block height.
-	header := stateless.LoadHeader(arguments.EnableStateRootCache, initHeight)
+	started := time.Now()
+	header, err := snapshots.LoadHeader(ordGetter, store, arguments.EnableStateRootCache, initHeight, loadHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the execution of the blocks recorded in the journal.
+	err = stateless.ResumeJournal(ordGetter, header, catchupHeight)
+	if err != nil {
+		return nil, err
+	}
+	metrics.StartupDuration.Set(time.Since(started).Seconds())
+	log.Printf("Loaded the state at height %d in %v", header.Height, time.Since(started))
 	curHeight := header.Height
 
 	log.Printf("Fast catchup to the lateset block height! From %d to %d \n", curHeight, latestHeight)
 
-	catchupHeight := latestHeight - ord.BitcoinConfirmations
-
-	// Create a channel to listen for SIGINT (Ctrl+C) signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT)
+	// Stop the catch-up process, the executed blocks are below the queue so they are confirmed.
+	interrupted := func() (*stateless.Queue, error) {
+		log.Printf("Saving cache file at height %d. Please don't force exit.", header.Height)
+		err := snapshots.StoreHeader(ordGetter, header)
+		if err != nil {
+			log.Printf("Failed to store the cache at height: %d", header.Height)
+		}
+		return nil, ctx.Err()
+	}
 
-	// Start to catch-up
-	// TODO: Medium. Refine the catchup performance by batching query.
+	// Start to catch-up, the upcoming blocks are fetched in batches while the current one is executed.
 	if catchupHeight > curHeight {
+		blocks := NewPrefetcher(ctx, ordGetter, curHeight+1, catchupHeight)
+		defer blocks.Close()
 		for i := curHeight + 1; i <= catchupHeight; i++ {
 			select {
-			case <-sigChan:
-				// SIGINT received, stop the catch-up process
-				log.Printf("Saving cache file. Please don't force exit.")
-				_ = stateless.StoreHeader(header, header.Height-2000)
-				os.Exit(0)
+			case <-ctx.Done():
+				return interrupted()
 			default:
-				ordTransfer, err := ordGetter.GetOrdTransfers(i)
+				ordTransfer, hash, err := blocks.Next()
 				if err != nil {
+					if ctx.Err() != nil {
+						return interrupted()
+					}
 					return nil, err
 				}
 				header.Lock()
 				stateless.Exec(header, ordTransfer, i)
-				_ = header.Paging(ordGetter, false, stateless.NodeResolveFn)
+				err = header.PagingWithHash(hash, stateless.NodeResolveFn)
 				header.Unlock()
+				if err != nil {
+					return nil, err
+				}
 				if i%1000 == 0 {
 					log.Printf("Blocks: %d / %d \n", i, catchupHeight)
-					if arguments.EnableStateRootCache {
-						err := stateless.StoreHeader(header, header.Height-2000)
-						if err != nil {
-							log.Printf("Failed to store the cache at height: %d", i)
-						}
+				}
+				if arguments.EnableStateRootCache && snapshots.Due(i) {
+					err := snapshots.StoreHeader(ordGetter, header)
+					if err != nil {
+						log.Printf("Failed to store the cache at height: %d", i)
 					}
 				}
 			}
@@ -87,13 +516,13 @@ func CatchupStage(ordGetter getter.OrdGetter, arguments *RuntimeArguments, initH
 	header.OrdTrans = ots
 
 	if arguments.EnableStateRootCache {
-		err := stateless.StoreHeader(header, header.Height-2000)
+		err := snapshots.StoreHeader(ordGetter, header)
 		if err != nil {
 			log.Printf("Failed to store the cache at height: %d", header.Height)
 		}
 	}
 
-	queue, err := stateless.NewQueues(ordGetter, header, true, catchupHeight+1)
+	queue, err := stateless.NewQueues(ordGetter, header, true, catchupHeight+1, depth)
 	if err != nil {
 		return nil, err
 	}
@@ -103,150 +532,310 @@ func CatchupStage(ordGetter getter.OrdGetter, arguments *RuntimeArguments, initH
 	return queue, nil
 }
 
-func ServiceStage(ordGetter getter.OrdGetter, arguments *RuntimeArguments, queue *stateless.Queue, interval time.Duration) {
+// DeepRecovery rebuilds the queue for a fork older than its history.
+// The state is reloaded from the newest snapshot at or below the fork, then replayed and executed forward to latestHeight.
+// The new queue is rebuilt in a fresh state store with its tree detached from the node store, so the old queue is
+// served meanwhile. It only takes the write lock of the queue to replace the old one.
+// The historical states above the fork are dropped from the history, if any.
+func DeepRecovery(ctx context.Context, ordGetter getter.OrdGetter, arguments *RuntimeArguments, queue *stateless.Queue, history *stateless.History, latestHeight uint) error {
+	queue.RLock()
+	startHeight, curHeight, queueDepth := queue.StartHeight(), queue.LatestHeight(), queue.Depth()
+	queue.RUnlock()
+	snapshots := NewSnapshotManager()
+	forkHeight, err := stateless.FindForkHeight(ordGetter, snapshots, startHeight-1)
+	if err != nil {
+		return err
+	}
+	depth := curHeight - forkHeight
+	log.Printf("Detected a deep reorg of depth %d from height %d, beyond the history of %d blocks", depth, forkHeight+1, queueDepth)
+	metrics.ReorgDepth.WithLabelValues("deep").Observe(float64(depth))
+
+	store, promote, err := NewRecoveryStateStore()
+	if err != nil {
+		return err
+	}
+	snapshots.Detached = true
+	newQueue, err := catchup(ctx, ordGetter, arguments, snapshots, store, stateless.BRC20StartHeight-1, forkHeight, latestHeight)
+	if err != nil {
+		return errors.Join(err, store.Close())
+	}
+
+	queue.Lock()
+	defer queue.Unlock()
+	err = newQueue.Header.Attach()
+	if err != nil {
+		return errors.Join(err, store.Close())
+	}
+	oldStore := queue.Header.KV
+	queue.Replace(newQueue)
+	if history != nil {
+		history.Invalidate(forkHeight + 1)
+	}
+	err = oldStore.Close()
+	if err == nil {
+		err = promote()
+	}
+	if err != nil {
+		log.Printf("Failed to replace the old state store: %v", err)
+	}
+	log.Printf("Recovered from the deep reorg at height %d", queue.LatestHeight())
+	return nil
+}
+
+// The interrupted deep recovery has stored its progress, the served queue is on the abandoned chain and isn't persisted.
+var errRecoveryInterrupted = errors.New("the deep recovery is interrupted")
+
+// FollowChain updates the queue to the latest block and recovers it from the reorgs.
+// A failed query leaves the queue at a consistent height, the deep recovery replaces the queue only once it is rebuilt.
+// The history, if any, drops its states of the abandoned chain after a reorg.
+func FollowChain(ctx context.Context, ordGetter getter.OrdGetter, arguments *RuntimeArguments, queue *stateless.Queue, history *stateless.History) error {
+	latestHeight, err := ordGetter.GetLatestBlockHeight()
+	if err != nil {
+		return fmt.Errorf("failed to get the latest block height: %w", err)
+	}
+
+	if queue.LatestHeight() < latestHeight {
+		metrics.Stage.Set(metrics.StageUpdating)
+		err := queue.Update(ordGetter, latestHeight)
+		if err != nil {
+			return fmt.Errorf("failed to update the queue: %w", err)
+		}
+	}
+
+	reorgHeight, err := queue.CheckForReorg(ordGetter)
+	if err != nil {
+		return fmt.Errorf("failed to check the reorganization: %w", err)
+	}
+	if reorgHeight == 0 {
+		return nil
+	}
+
+	metrics.Stage.Set(metrics.StageReorg)
+	if queue.IsDeepReorg(reorgHeight) {
+		err = DeepRecovery(ctx, ordGetter, arguments, queue, history, latestHeight)
+		if err != nil && ctx.Err() != nil {
+			return errRecoveryInterrupted
+		}
+		if err != nil {
+			log.Fatalf("Failed to recover from the deep reorg: %v", err)
+		}
+		return nil
+	}
+	depth := queue.LatestHeight() - reorgHeight + 1
+	log.Printf("Detected a reorg of depth %d from height %d", depth, reorgHeight)
+	metrics.ReorgDepth.WithLabelValues("shallow").Observe(float64(depth))
+	err = queue.Recovery(ordGetter, reorgHeight)
+	if history != nil {
+		// The recovery may have failed halfway, the states from the reorg height are stale either way.
+		history.Invalidate(reorgHeight)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to recover the queue from the reorg: %w", err)
+	}
+	return nil
+}
+
+// PublishCheckpoints enqueues the checkpoints of the states in the reorg window into the outbox, drops the ones
+// out of the window, and reports the pending ones. Each checkpoint is reported by each reporter until it succeeds, once.
+// The checkpoints are signed by the key of the indexer, and each one is chained to the checkpoint of the parent block.
+// The queued checkpoints are never rebuilt, so the published ones keep their digests.
+func PublishCheckpoints(outbox *checkpoint.Outbox, reporters []checkpoint.Reporter, key *btcec.PrivateKey, arguments *RuntimeArguments, queue *stateless.Queue) error {
+	destinations := make([]string, 0, len(reporters))
+	for _, reporter := range reporters {
+		destinations = append(destinations, reporter.Name())
+	}
+
+	latestHistory := stateless.DiffState{
+		Height:         queue.Header.Height,
+		Hash:           queue.Header.Hash,
+		VerkleCommit:   queue.Header.Root.Commit().Bytes(),
+		OrdTransDigest: getter.DigestOrdTransfers(queue.Header.OrdTrans),
+	}
+	states := append(append([]stateless.DiffState{}, queue.History...), latestHistory)
+	indexerID := NewIndexerIdentification(arguments)
+	indexerID.PublicKey = checkpoint.PublicKeyHex(key)
+	hashes := make(map[uint]string, len(states))
+	// The first checkpoint of the window starts a new chain unless it is queued already, e.g. on the first start.
+	var prev *checkpoint.Checkpoint
+	for _, state := range states {
+		entry, err := outbox.Entry(state.Height, state.Hash)
+		if err != nil {
+			return err
+		}
+		var c checkpoint.Checkpoint
+		if entry != nil {
+			c = entry.Checkpoint
+		} else {
+			commitment := base64.StdEncoding.EncodeToString(state.VerkleCommit[:])
+			c = checkpoint.NewCheckpoint(&indexerID, state.Height, state.Hash, commitment)
+			c.TransfersDigest = hex.EncodeToString(state.OrdTransDigest[:])
+			if prev != nil {
+				c.Link(prev)
+			}
+			checkpoint.Sign(&c, key)
+		}
+		err = outbox.Enqueue(state.Height, c, destinations)
+		if err != nil {
+			return err
+		}
+		hashes[state.Height] = state.Hash
+		prev = &c
+	}
+	undelivered, err := outbox.Retain(hashes)
+	if err != nil {
+		return err
+	}
+	if undelivered > 0 {
+		log.Printf("Dropped %d checkpoints out of the reorg window before they were uploaded", undelivered)
+	}
+	return outbox.Deliver(reporters, time.Now())
+}
+
+// ServiceStage follows the new blocks until ctx is done, then drains the API requests and persists the state by Shutdown.
+// The new blocks are checked every interval, or as soon as they are notified if the notification is enabled.
+func ServiceStage(ctx context.Context, ordGetter getter.OrdGetter, arguments *RuntimeArguments, queue *stateless.Queue, interval time.Duration) {
 	metrics.Stage.Set(metrics.StageServing)
 
-	// Create a channel to listen for SIGINT (Ctrl+C) signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT)
+	newBlocks := SubscribeNewBlocks(ctx, ordGetter)
 
-	var history = make(map[string]checkpoint.UploadRecord)
+	// Closed once the API service has drained the in-flight requests.
+	served := make(chan struct{})
 
+	var outbox *checkpoint.Outbox
+	var reporters []checkpoint.Reporter
+	var key *btcec.PrivateKey
+	if arguments.EnableCommittee {
+		var err error
+		key, err = LoadIndexerKey()
+		if err != nil {
+			log.Fatalf("Failed to load the indexer key: %v", err)
+		}
+		reporters, err = NewReporters()
+		if err != nil {
+			log.Fatalf("Failed to initial the checkpoint reporters: %v", err)
+		}
+		outbox, err = NewOutbox()
+		if err != nil {
+			log.Fatalf("Failed to open the checkpoint outbox: %v", err)
+		}
+		checkpointOutbox = outbox
+		defer func() {
+			checkpointOutbox = nil
+			_ = outbox.Close()
+		}()
+	}
+
+	var history *stateless.History
 	if arguments.EnableService {
 		if arguments.CommitteeIndexerURL != "" {
 			log.Printf("Providing API service at: %s", arguments.CommitteeIndexerURL)
 		} else {
 			log.Printf("Providing API service at: %s", GlobalConfig.Service.URL)
 		}
-		go apis.StartService(queue, arguments.EnableCommittee, arguments.EnableTest, arguments.EnablePprof)
+		var err error
+		history, err = NewHistory()
+		if err != nil {
+			log.Fatalf("Failed to initial the historical states: %v", err)
+		}
+		go func() {
+			apis.StartService(ctx, queue, history, arguments.EnableCommittee, arguments.EnableTest, arguments.EnablePprof)
+			close(served)
+		}()
+	} else {
+		close(served)
 	}
 
 	for {
 		select {
-		case <-sigChan:
-			// TODO: High. Save the latest state is unsound if reorg happened.
-			// log.Printf("Saving cache file. Please don't force exit.")
-			// stateless.StoreHeader(queue.Header, queue.Header.Height-2000)
-			os.Exit(0)
-		default:
-			curHeight := queue.LatestHeight()
-			latestHeight, err := ordGetter.GetLatestBlockHeight()
+		case <-ctx.Done():
+			<-served
+			err := Shutdown(ordGetter, arguments, queue)
 			if err != nil {
-				log.Fatalf("Failed to get the latest block height: %v", err)
+				log.Printf("Failed to shut down cleanly: %v", err)
 			}
-
-			if curHeight < latestHeight {
-				metrics.Stage.Set(metrics.StageUpdating)
-				err := queue.Update(ordGetter, latestHeight)
-				if err != nil {
-					log.Fatalf("Failed to update the queue: %v", err)
-				}
-				metrics.Stage.Set(metrics.StageServing)
+			return
+		default:
+			err := FollowChain(ctx, ordGetter, arguments, queue, history)
+			if errors.Is(err, errRecoveryInterrupted) {
+				<-served
+				_ = stateless.CloseStores()
+				return
 			}
-
-			reorgHeight, err := queue.CheckForReorg(ordGetter)
-
 			if err != nil {
-				log.Fatalf("Failed to check the reorganization: %v", err)
+				// The queue stays at a consistent height, it is served as is and updated in the next round.
+				log.Printf("Serving the stale state at height %d: %v", queue.LatestHeight(), err)
+				metrics.Stage.Set(metrics.StageStale)
+			} else {
+				metrics.Stage.Set(metrics.StageServing)
 			}
 
-			if reorgHeight != 0 {
-				metrics.Stage.Set(metrics.StageReorg)
-				err := queue.Recovery(ordGetter, reorgHeight)
+			// The checkpoints are only published for the state following the chain.
+			if outbox != nil && err == nil {
+				err := PublishCheckpoints(outbox, reporters, key, arguments, queue)
 				if err != nil {
-					log.Fatalf("Failed to update the queue: %v", err)
+					log.Printf("Failed to publish the checkpoints: %v", err)
 				}
-				metrics.Stage.Set(metrics.StageServing)
 			}
+			if !arguments.EnableTest {
+				log.Printf("Listening for new Bitcoin block, current height: %d\n", queue.LatestHeight())
+			}
+			select {
+			case <-ctx.Done():
+			case <-newBlocks:
+			case <-time.After(interval):
+			}
+		}
+	}
+}
 
-			if arguments.EnableCommittee {
-				latestHistory := stateless.DiffState{
-					Height:       queue.Header.Height,
-					Hash:         queue.Header.Hash,
-					VerkleCommit: queue.Header.Root.Commit().Bytes(),
-					Access:       stateless.AccessList{},
-				}
-				hs := make([]*stateless.DiffState, 0)
-				for _, i := range queue.History {
-					hs = append(hs, &i)
-				}
-				hs = append(hs, &latestHistory)
-				for _, i := range hs {
-					key := fmt.Sprintf("%d", i.Height) + i.Hash
-					if curRecord, found := history[key]; !(found && curRecord.Success) {
-						committeeIndexerName := GlobalConfig.Service.Name
-						if arguments.CommitteeIndexerName != "" {
-							committeeIndexerName = arguments.CommitteeIndexerName
-						}
-						serviceURL := GlobalConfig.Service.URL
-						if arguments.CommitteeIndexerURL != "" {
-							serviceURL = arguments.CommitteeIndexerURL
-						}
-						metaProtocol := GlobalConfig.Service.MetaProtocol
-						if arguments.ProtocolName != "" {
-							metaProtocol = arguments.ProtocolName
-						}
-						indexerID := checkpoint.IndexerIdentification{
-							URL:          serviceURL,
-							Name:         committeeIndexerName,
-							Version:      version,
-							MetaProtocol: metaProtocol,
-						}
-						commitment := base64.StdEncoding.EncodeToString(i.VerkleCommit[:])
-						c := checkpoint.NewCheckpoint(&indexerID, i.Height, i.Hash, commitment)
-						timeout := time.Duration(GlobalConfig.Report.Timeout) * time.Millisecond
-						if GlobalConfig.Report.Method == "S3" {
-							log.Printf("Uploading the checkpoint by S3 at height: %s\n", c.Height)
-							s3cfg := GlobalConfig.Report.S3
-							err = checkpoint.UploadCheckpointByS3(&c,
-								s3cfg.AccessKey, s3cfg.SecretKey, s3cfg.Region, s3cfg.Bucket, timeout)
-							if err != nil {
-								log.Printf("Unable to upload the checkpoint by S3 due to: %v", err)
-							} else {
-								log.Printf("Succeed to upload the checkpoint by S3 at height: %s\n", c.Height)
-							}
-						} else if GlobalConfig.Report.Method == "DA" {
-							log.Printf("Uploading the checkpoint by DA at height: %s\n", c.Height)
-							dacfg := GlobalConfig.Report.Da
-							err = checkpoint.UploadCheckpointByDA(&c,
-								dacfg.PrivateKey, dacfg.GasCoupon, dacfg.NamespaceID, dacfg.Network, timeout)
-							if err != nil {
-								log.Printf("Unable to upload the checkpoint by DA due to: %v", err)
-							} else {
-								log.Printf("Succeed to upload the checkpoint by DA at height: %s\n", c.Height)
-							}
-						}
-						history[key] = checkpoint.UploadRecord{
-							Success: true,
-						}
-					}
-				}
+// Shutdown persists the state of the queue and closes the stores, the caller must have stopped updating the queue.
+// The snapshot is taken at the start of the queue, which has at least BitcoinConfirmations blocks on top if the reorg depth
+// is large enough, so the next start never loads a state abandoned by a reorg. The queue is rolled back in place for it.
+func Shutdown(ordGetter getter.OrdGetter, arguments *RuntimeArguments, queue *stateless.Queue) error {
+	metrics.Stage.Set(metrics.StageStopping)
+	var errs []error
+	if arguments.EnableStateRootCache {
+		height := queue.StartHeight()
+		if queue.LatestHeight()-height < ord.BitcoinConfirmations {
+			log.Printf("Skip the cache file on shutdown, the reorg depth %d is below %d confirmations", queue.Depth(), ord.BitcoinConfirmations)
+		} else {
+			// RollbackTo waits for the in-progress update or recovery of the queue.
+			err := queue.RollbackTo(height)
+			if err == nil {
+				log.Printf("Saving cache file at height %d. Please don't force exit.", height)
+				err = NewSnapshotManager().StoreHeader(ordGetter, queue.Header)
 			}
-			if !arguments.EnableTest {
-				log.Printf("Listening for new Bitcoin block, current height: %d\n", latestHeight)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to store the cache at height %d: %w", height, err))
 			}
-			time.Sleep(interval)
 		}
 	}
+	errs = append(errs, queue.Header.KV.Close(), stateless.CloseStores())
+	return errors.Join(errs...)
 }
 
 func Execution(arguments *RuntimeArguments) {
-	go metrics.ListenAndServe(arguments.MetricAddr)
+	// SIGTERM is sent by the orchestrators, SIGINT by Ctrl+C.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Printf("Received the stop signal, shutting down. Send it again to force exit.")
+		// Restore the default behavior, so the second signal kills the process.
+		stop()
+	}()
+
+	go metrics.ListenAndServe(ctx, arguments.MetricAddr)
 	metrics.Version.WithLabelValues(version).Set(1)
 	metrics.Stage.Set(metrics.StageInitializing)
 
 	// Get the configuration.
-	configFile, err := os.ReadFile(arguments.ConfigFilePath)
+	err := LoadConfig(arguments.ConfigFilePath)
 	if err != nil {
-		log.Fatalf("Failed to read config file: %v", err)
+		log.Fatalf("Failed to load config file: %v", err)
 	}
 
-	err = json.Unmarshal(configFile, &GlobalConfig)
-	if err != nil {
-		log.Fatalf("Failed to parse config file: %v", err)
-	}
-
-	if GlobalConfig.Report.Method == "DA" && arguments.EnableCommittee {
+	if arguments.EnableCommittee && ReportsToDA() {
 		if !checkpoint.IsValidNamespaceID(GlobalConfig.Report.Da.NamespaceID) {
 			log.Printf("Got invalid Namespace ID from the config.json. Initializing a new namespace.")
 			scanner := bufio.NewScanner(os.Stdin)
@@ -279,30 +868,37 @@ func Execution(arguments *RuntimeArguments) {
 		}
 	}
 
-	// Use OPI database as the ordGetter.
-	gd := getter.DatabaseConfig(GlobalConfig.Database)
+	// Use OPI database or the ord server as the ordGetter.
 	var ordGetter getter.OrdGetter
 	if arguments.EnableTest {
+		gd := getter.DatabaseConfig(GlobalConfig.Database)
 		ordGetter, err = getter.NewOPIOrdGetterTest(&gd, arguments.TestBlockHeightLimit, arguments.TestBlockHeightLimit)
 	} else {
-		ordGetter, err = getter.NewOPIOrdGetter(&gd)
+		ordGetter, err = NewOrdGetter(arguments)
 	}
 	if err != nil {
-		log.Fatalf("Failed to initial getter from opi database: %v", err)
+		log.Fatalf("Failed to initial the getter: %v", err)
 	}
+	ordGetter = NewResilientGetter(ctx, ordGetter)
 
 	latestHeight, err := ordGetter.GetLatestBlockHeight()
 	if err != nil {
 		log.Fatalf("Failed to get the latest block height: %v", err)
 	}
 
-	queue, err := CatchupStage(ordGetter, arguments, stateless.BRC20StartHeight-1, latestHeight)
+	queue, err := CatchupStage(ctx, ordGetter, arguments, stateless.BRC20StartHeight-1, latestHeight)
 
 	if err != nil {
+		if ctx.Err() != nil {
+			_ = stateless.CloseStores()
+			log.Printf("Stopped during the catchup.")
+			return
+		}
 		log.Fatalf("Failed to catchup the latest state: %v", err)
 	}
 
-	ServiceStage(ordGetter, arguments, queue, 60*time.Second)
+	ServiceStage(ctx, ordGetter, arguments, queue, PollInterval())
+	log.Printf("Stopped.")
 }
 
 func main() {
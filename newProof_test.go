@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"log"
@@ -15,12 +16,12 @@ import (
 func Test_NewProof(t *testing.T) {
 	var latestHeight uint = stateless.BRC20StartHeight + ord.BitcoinConfirmations
 	ordGetterTest, arguments := loadMain(782000)
-	queue, err := CatchupStage(ordGetterTest, &arguments, stateless.BRC20StartHeight-1, latestHeight)
+	queue, err := CatchupStage(context.Background(), ordGetterTest, &arguments, stateless.BRC20StartHeight-1, latestHeight)
 	if err != nil {
 		log.Fatalf(fmt.Sprintf("error happened: %v", err))
 	}
 	ordGetterTest.SetLatestBlockHeight(latestHeight)
-	go ServiceStage(ordGetterTest, &arguments, queue, 10*time.Millisecond)
+	go ServiceStage(context.Background(), ordGetterTest, &arguments, queue, 10*time.Millisecond)
 	for {
 		curHeight, _ := ordGetterTest.GetLatestBlockHeight()
 		if height := curHeight; height == queue.LatestHeight() {
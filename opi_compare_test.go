@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"testing"
@@ -17,12 +18,12 @@ func Test_OPI(t *testing.T) {
 		log.Fatalf(fmt.Sprintf("error happened: %v", err))
 	}
 	ordGetterTest, arguments := loadMain(782000)
-	queue, err := CatchupStage(ordGetterTest, &arguments, stateless.BRC20StartHeight-1, latestHeight)
+	queue, err := CatchupStage(context.Background(), ordGetterTest, &arguments, stateless.BRC20StartHeight-1, latestHeight)
 	if err != nil {
 		log.Fatalf(fmt.Sprintf("error happened: %v", err))
 	}
 	ordGetterTest.SetLatestBlockHeight(latestHeight)
-	go ServiceStage(ordGetterTest, &arguments, queue, 10*time.Millisecond)
+	go ServiceStage(context.Background(), ordGetterTest, &arguments, queue, 10*time.Millisecond)
 	for {
 		curHeight, _ := ordGetterTest.GetLatestBlockHeight()
 		if curHeight == queue.LatestHeight() {
@@ -0,0 +1,154 @@
+package getter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DigestOrdTransfers returns the SHA-256 of the ordered transfers of a block, committed by the checkpoints.
+// The transfers are the brc-20 ones served by the getters: the inscriptions not cursed for brc-20 whose content
+// is a JSON object with "p" being "brc-20". Each field is prefixed by its length, and the fields whose
+// representations depend on the source are canonicalized, so the indexers reading OPI, ord or the recorded
+// files agree on the digest of the same block:
+//   - The IDs are left out, they are assigned by the source.
+//   - The content is re-encoded by canonicalContent, OPI stores it as jsonb while ord serves the raw bytes.
+//   - The content type is hex decoded if it is hex, OPI stores it hex encoded, like Exec reads it.
+//   - The pkscript and the wallet of the transfers sent as fee are left out, the brc-20 rules ignore them and
+//     the sources disagree on the inscriptions lost as fee.
+func DigestOrdTransfers(ordTransfers []OrdTransfer) [32]byte {
+	h := sha256.New()
+	var buf [8]byte
+	writeUint := func(v uint64) {
+		binary.BigEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	writeBytes := func(b []byte) {
+		writeUint(uint64(len(b)))
+		h.Write(b)
+	}
+	writeUint(uint64(len(ordTransfers)))
+	for _, ot := range ordTransfers {
+		writeBytes([]byte(ot.InscriptionID))
+		writeUint(uint64(ot.BlockHeight))
+		writeBytes([]byte(ot.OldSatpoint))
+		writeBytes([]byte(ot.NewSatpoint))
+		if ot.SentAsFee {
+			writeBytes(nil)
+			writeBytes(nil)
+			writeUint(1)
+		} else {
+			writeBytes([]byte(ot.NewPkscript))
+			writeBytes([]byte(ot.NewWallet))
+			writeUint(0)
+		}
+		writeBytes(canonicalContent(ot.Content))
+		contentType := []byte(ot.ContentType)
+		if decoded, err := hex.DecodeString(ot.ContentType); err == nil {
+			contentType = decoded
+		}
+		writeBytes(contentType)
+		writeBytes([]byte(ot.ParentID))
+	}
+	var digest [32]byte
+	h.Sum(digest[:0])
+	return digest
+}
+
+// canonicalContent re-encodes the JSON content with the object keys sorted and deduplicated by the last one, no
+// whitespace, the strings escaped by encoding/json and the numbers normalized by canonicalNumber.
+// The content that isn't JSON is returned as is.
+func canonicalContent(content []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var v any
+	if decoder.Decode(&v) != nil || decoder.More() {
+		return content
+	}
+	var buf bytes.Buffer
+	if writeCanonical(&buf, v) != nil {
+		return content
+	}
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case json.Number:
+		buf.WriteString(canonicalNumber(v.String()))
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(encoded)
+	}
+	return nil
+}
+
+// canonicalNumber writes the JSON number as its significant digits and the decimal exponent, e.g. 1.50 and 15e-1
+// are both 15e-1, like the numeric of jsonb compares them. It works on the text, so a huge exponent costs nothing.
+func canonicalNumber(number string) string {
+	sign := ""
+	mantissa := number
+	if strings.HasPrefix(mantissa, "-") {
+		sign, mantissa = "-", mantissa[1:]
+	}
+	var exponent int64
+	if i := strings.IndexAny(mantissa, "eE"); i >= 0 {
+		var err error
+		exponent, err = strconv.ParseInt(mantissa[i+1:], 10, 32)
+		if err != nil {
+			return number
+		}
+		mantissa = mantissa[:i]
+	}
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	exponent -= int64(len(fracPart))
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	if digits == "" {
+		return "0"
+	}
+	trimmed := strings.TrimRight(digits, "0")
+	exponent += int64(len(digits) - len(trimmed))
+	if exponent == 0 {
+		return sign + trimmed
+	}
+	return sign + trimmed + "e" + strconv.FormatInt(exponent, 10)
+}
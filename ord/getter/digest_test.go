@@ -0,0 +1,57 @@
+package getter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDigestOrdTransfers(t *testing.T) {
+	a := OrdTransfer{ID: 1, InscriptionID: "a", BlockHeight: 780000, NewSatpoint: "tx:0:0", Content: []byte(`{"p":"brc-20"}`)}
+	b := OrdTransfer{ID: 2, InscriptionID: "b", BlockHeight: 780000, OldSatpoint: "tx:0:0", NewSatpoint: "tx2:0:0", SentAsFee: true}
+	digest := DigestOrdTransfers([]OrdTransfer{a, b})
+
+	renumbered := []OrdTransfer{a, b}
+	renumbered[0].ID, renumbered[1].ID = 10, 11
+	if DigestOrdTransfers(renumbered) != digest {
+		t.Fatal("the digest depends on the IDs")
+	}
+	if DigestOrdTransfers([]OrdTransfer{b, a}) == digest {
+		t.Fatal("the digest ignores the order")
+	}
+	tampered := []OrdTransfer{a, b}
+	tampered[1].SentAsFee = false
+	if DigestOrdTransfers(tampered) == digest {
+		t.Fatal("the digest ignores the fee")
+	}
+	// The fields are delimited by their lengths.
+	shifted := []OrdTransfer{a, b}
+	shifted[0].InscriptionID, shifted[0].OldSatpoint = "at", "x:0:0"
+	moved := []OrdTransfer{a, b}
+	moved[0].InscriptionID, moved[0].OldSatpoint = "a", "tx:0:0"
+	if DigestOrdTransfers(shifted) == DigestOrdTransfers(moved) {
+		t.Fatal("the digest mixes the fields")
+	}
+	if DigestOrdTransfers(nil) != DigestOrdTransfers([]OrdTransfer{}) || DigestOrdTransfers(nil) == digest {
+		t.Fatal("unexpected digest of an empty block")
+	}
+}
+
+func TestCanonicalContent(t *testing.T) {
+	raw := `{ "p": "brc-20", "op": "mint", "tick": "ordi", "amt": "1000", "n": 1.50, "m": [1e2, -0.0], "op": "deploy" }`
+	// The jsonb of OPI drops the duplicated keys, orders the keys by length and spaces the separators.
+	jsonb := `{"m": [100, -0.0], "n": 1.50, "p": "brc-20", "op": "deploy", "amt": "1000", "tick": "ordi"}`
+	if !bytes.Equal(canonicalContent([]byte(raw)), canonicalContent([]byte(jsonb))) {
+		t.Fatalf("mismatched canonical content: %s and %s", canonicalContent([]byte(raw)), canonicalContent([]byte(jsonb)))
+	}
+	if expected := `{"amt":"1000","m":[1e2,0],"n":15e-1,"op":"deploy","p":"brc-20","tick":"ordi"}`; string(canonicalContent([]byte(raw))) != expected {
+		t.Fatalf("unexpected canonical content: %s", canonicalContent([]byte(raw)))
+	}
+	if canonicalNumber("1e999999999999") != "1e999999999999" || canonicalNumber("-12.300e+3") != "-123e2" {
+		t.Fatal("unexpected canonical numbers")
+	}
+	for _, content := range []string{"\x89PNG", `{"p":"brc-20"} {}`, ""} {
+		if string(canonicalContent([]byte(content))) != content {
+			t.Fatalf("the content isn't JSON but changed: %q", content)
+		}
+	}
+}
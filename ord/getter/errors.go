@@ -0,0 +1,12 @@
+package getter
+
+import "errors"
+
+var (
+	// ErrBlockNotFound is returned if the block isn't indexed by OPI, e.g. it is removed while OPI handles a reorg.
+	ErrBlockNotFound = errors.New("block not found")
+	// ErrBehindTip is returned if the block is above the latest block indexed by OPI.
+	ErrBehindTip = errors.New("block is above the indexed tip")
+	// ErrCircuitOpen is returned without querying while the circuit breaker is open after consecutive failures.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
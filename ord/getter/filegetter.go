@@ -0,0 +1,614 @@
+package getter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord"
+)
+
+// The formats of the recorded files, chosen by the extension before the optional .gz.
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+func fileFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".gz"))) {
+	case ".csv":
+		return formatCSV, nil
+	case ".jsonl", ".ndjson":
+		return formatJSONL, nil
+	}
+	return "", fmt.Errorf("unknown format of %s, expected .csv or .jsonl optionally gzipped", path)
+}
+
+// isGzipped tells whether the file starts with the gzip magic number, the file is rewound.
+func isGzipped(file *os.File) (bool, error) {
+	magic := make([]byte, 2)
+	n, _ := io.ReadFull(file, magic)
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return false, err
+	}
+	return n == 2 && magic[0] == 0x1f && magic[1] == 0x8b, nil
+}
+
+// openFile opens the file and decompresses it if it is gzipped.
+func openFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := isGzipped(file)
+	if err != nil || !compressed {
+		if err != nil {
+			file.Close()
+		}
+		return file, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}, nil
+}
+
+// spillMemberSize is the decompressed size of the gzip members of the spill file, see spillWriter.
+var spillMemberSize int64 = 1 << 20
+
+// A gzip member starting at offset in the file and at start in the decompressed content.
+type gzipMember struct {
+	offset int64
+	start  int64
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
+
+// spillWriter recompresses the records of a gzipped file into a temporary file while the file is indexed.
+// A gzip stream is only decompressed from its start, so the records are cut into gzip members of about
+// spillMemberSize bytes at the record boundaries, and a block is read by decompressing from the start of its member.
+type spillWriter struct {
+	file       *os.File
+	buffer     *bufio.Writer
+	compressed *countingWriter
+	gzip       *gzip.Writer
+	content    *countingWriter
+	csv        *csv.Writer
+	members    []gzipMember
+	// The file is unlinked once created so it is never left behind, unless the platform can't unlink an open file.
+	unlinked bool
+}
+
+func newSpillWriter() (*spillWriter, error) {
+	file, err := os.CreateTemp("", "transfers-*.gz")
+	if err != nil {
+		return nil, err
+	}
+	buffer := bufio.NewWriterSize(file, 1<<20)
+	compressed := &countingWriter{writer: buffer}
+	writer, err := gzip.NewWriterLevel(compressed, gzip.BestSpeed)
+	if err != nil {
+		return nil, errors.Join(err, file.Close(), os.Remove(file.Name()))
+	}
+	content := &countingWriter{writer: writer}
+	return &spillWriter{
+		unlinked:   os.Remove(file.Name()) == nil,
+		file:       file,
+		buffer:     buffer,
+		compressed: compressed,
+		gzip:       writer,
+		content:    content,
+		csv:        csv.NewWriter(content),
+		members:    []gzipMember{{}},
+	}, nil
+}
+
+// write appends the record and returns its byte range in the decompressed content of the spill file.
+func (w *spillWriter) write(record *fileRecord) (int64, int64, error) {
+	if w.content.count-w.members[len(w.members)-1].start >= spillMemberSize {
+		err := w.gzip.Close()
+		if err != nil {
+			return 0, 0, err
+		}
+		w.members = append(w.members, gzipMember{offset: w.compressed.count, start: w.content.count})
+		w.gzip.Reset(w.compressed)
+	}
+	offset := w.content.count
+	var err error
+	if record.json != nil {
+		_, err = w.content.Write(record.json)
+		if err == nil {
+			_, err = w.content.Write([]byte{'\n'})
+		}
+	} else {
+		err = w.csv.Write(record.csv)
+		if err == nil {
+			w.csv.Flush()
+			err = w.csv.Error()
+		}
+	}
+	return offset, w.content.count - offset, err
+}
+
+// finish writes out the spill file, it is read by the byte ranges returned by write.
+func (w *spillWriter) finish() error {
+	err := w.gzip.Close()
+	if err != nil {
+		return err
+	}
+	return w.buffer.Flush()
+}
+
+// A record of the recorded files, the CSV row or the JSON line, with its byte range in the file.
+type fileRecord struct {
+	csv    []string
+	json   []byte
+	offset int64
+	length int64
+}
+
+// recordReader streams the records of a CSV file with a header or of a JSON lines file.
+type recordReader struct {
+	format string
+	// The CSV columns by name.
+	columns map[string]int
+	csv     *csv.Reader
+	lines   *bufio.Reader
+	offset  int64
+}
+
+func newRecordReader(r io.Reader, format string, columns map[string]int) (*recordReader, error) {
+	reader := recordReader{
+		format:  format,
+		columns: columns,
+	}
+	if format == formatJSONL {
+		reader.lines = bufio.NewReader(r)
+		return &reader, nil
+	}
+	reader.csv = csv.NewReader(r)
+	// The header is skipped when the columns are known, i.e. reading a segment of the file.
+	if columns != nil {
+		return &reader, nil
+	}
+	header, err := reader.csv.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+	reader.columns = make(map[string]int, len(header))
+	for i, name := range header {
+		reader.columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	reader.offset = reader.csv.InputOffset()
+	return &reader, nil
+}
+
+// next returns io.EOF after the last record.
+func (r *recordReader) next() (*fileRecord, error) {
+	if r.format == formatJSONL {
+		for {
+			line, err := r.lines.ReadBytes('\n')
+			record := fileRecord{
+				json:   bytes.TrimSpace(line),
+				offset: r.offset,
+				length: int64(len(line)),
+			}
+			r.offset += int64(len(line))
+			if len(record.json) > 0 {
+				return &record, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	fields, err := r.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+	record := fileRecord{
+		csv:    fields,
+		offset: r.offset,
+		length: r.csv.InputOffset() - r.offset,
+	}
+	r.offset = r.csv.InputOffset()
+	return &record, nil
+}
+
+// field returns the CSV field of the column, empty if the column is absent.
+func (r *recordReader) field(record *fileRecord, column string) string {
+	if i, ok := r.columns[column]; ok {
+		return record.csv[i]
+	}
+	return ""
+}
+
+func (r *recordReader) require(columns ...string) error {
+	if r.format != formatCSV {
+		return nil
+	}
+	for _, column := range columns {
+		if _, ok := r.columns[column]; !ok {
+			return fmt.Errorf("missing column %s", column)
+		}
+	}
+	return nil
+}
+
+func (r *recordReader) blockHash(record *fileRecord) (uint, string, error) {
+	if r.format == formatJSONL {
+		var row struct {
+			BlockHeight uint   `json:"block_height"`
+			BlockHash   string `json:"block_hash"`
+		}
+		err := json.Unmarshal(record.json, &row)
+		return row.BlockHeight, row.BlockHash, err
+	}
+	blockHeight, err := strconv.ParseUint(r.field(record, "block_height"), 10, 64)
+	return uint(blockHeight), r.field(record, "block_hash"), err
+}
+
+func (r *recordReader) blockHeight(record *fileRecord) (uint, error) {
+	if r.format == formatJSONL {
+		var row struct {
+			BlockHeight uint `json:"block_height"`
+		}
+		err := json.Unmarshal(record.json, &row)
+		return row.BlockHeight, err
+	}
+	blockHeight, err := strconv.ParseUint(r.field(record, "block_height"), 10, 64)
+	return uint(blockHeight), err
+}
+
+func (r *recordReader) ordTransfer(record *fileRecord) (OrdTransfer, error) {
+	if r.format == formatJSONL {
+		var row struct {
+			ID            uint            `json:"id"`
+			InscriptionID string          `json:"inscription_id"`
+			BlockHeight   uint            `json:"block_height"`
+			OldSatpoint   string          `json:"old_satpoint"`
+			NewSatpoint   string          `json:"new_satpoint"`
+			NewPkscript   string          `json:"new_pkscript"`
+			NewWallet     string          `json:"new_wallet"`
+			SentAsFee     bool            `json:"sent_as_fee"`
+			Content       json.RawMessage `json:"content"`
+			ContentType   string          `json:"content_type"`
+			ParentID      string          `json:"parent_id"`
+		}
+		err := json.Unmarshal(record.json, &row)
+		if err != nil {
+			return OrdTransfer{}, err
+		}
+		// The content is either the JSON of OPI or a string holding it.
+		content := []byte(row.Content)
+		var text string
+		if json.Unmarshal(row.Content, &text) == nil {
+			content = []byte(text)
+		} else if bytes.Equal(row.Content, []byte("null")) {
+			content = nil
+		}
+		return OrdTransfer{
+			ID:            row.ID,
+			InscriptionID: row.InscriptionID,
+			BlockHeight:   row.BlockHeight,
+			OldSatpoint:   row.OldSatpoint,
+			NewSatpoint:   row.NewSatpoint,
+			NewPkscript:   ord.Pkscript(row.NewPkscript),
+			NewWallet:     ord.Wallet(row.NewWallet),
+			SentAsFee:     row.SentAsFee,
+			Content:       content,
+			ContentType:   row.ContentType,
+			ParentID:      row.ParentID,
+		}, nil
+	}
+	id, err := strconv.ParseUint(r.field(record, "id"), 10, 64)
+	if err != nil {
+		return OrdTransfer{}, fmt.Errorf("invalid id: %w", err)
+	}
+	blockHeight, err := strconv.ParseUint(r.field(record, "block_height"), 10, 64)
+	if err != nil {
+		return OrdTransfer{}, fmt.Errorf("invalid block_height: %w", err)
+	}
+	var sentAsFee bool
+	if value := r.field(record, "sent_as_fee"); value != "" {
+		sentAsFee, err = strconv.ParseBool(value)
+		if err != nil {
+			return OrdTransfer{}, fmt.Errorf("invalid sent_as_fee: %w", err)
+		}
+	}
+	return OrdTransfer{
+		ID:            uint(id),
+		InscriptionID: r.field(record, "inscription_id"),
+		BlockHeight:   uint(blockHeight),
+		OldSatpoint:   r.field(record, "old_satpoint"),
+		NewSatpoint:   r.field(record, "new_satpoint"),
+		NewPkscript:   ord.Pkscript(r.field(record, "new_pkscript")),
+		NewWallet:     ord.Wallet(r.field(record, "new_wallet")),
+		SentAsFee:     sentAsFee,
+		Content:       []byte(r.field(record, "content")),
+		ContentType:   r.field(record, "content_type"),
+		ParentID:      r.field(record, "parent_id"),
+	}, nil
+}
+
+// A byte range of consecutive records of the same block.
+type fileSegment struct {
+	offset int64
+	length int64
+}
+
+// FileOrdGetter reads the blocks recorded from OPI, e.g. by the queries of OPIOrdGetter exported with COPY.
+// The block hashes are loaded into memory. The transfers are streamed once to index their byte ranges by the
+// block height and read on demand. The gzipped transfers are recompressed into a temporary spill file along the
+// single pass, and their byte ranges are in its decompressed content, see spillWriter.
+// The transfers of a block keep their order in the file, the latest block is the highest recorded block hash.
+type FileOrdGetter struct {
+	latestBlockHeight uint
+	blockHashes       map[uint]string
+
+	// The columns of the CSV transfers.
+	format  string
+	columns map[string]int
+	// The indexed file, or the spill file of the gzipped transfers with its gzip members.
+	file *os.File
+	// The spill file to remove on Close, see spillWriter.
+	spill    bool
+	members  []gzipMember
+	segments map[uint][]fileSegment
+}
+
+// NewFileOrdGetter indexes the block hashes with the columns block_height and block_hash, and the transfers with
+// the columns of ord_transfers as selected by OPIOrdGetter. The files are CSV with a header or JSON lines.
+func NewFileOrdGetter(blockHashesPath string, transfersPath string) (*FileOrdGetter, error) {
+	getter := FileOrdGetter{
+		blockHashes: make(map[uint]string),
+	}
+	err := getter.loadBlockHashes(blockHashesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the block hashes from %s: %w", blockHashesPath, err)
+	}
+	err = getter.indexTransfers(transfersPath)
+	if err != nil {
+		_ = getter.Close()
+		return nil, fmt.Errorf("failed to index the transfers from %s: %w", transfersPath, err)
+	}
+	return &getter, nil
+}
+
+func (g *FileOrdGetter) loadBlockHashes(path string) error {
+	format, err := fileFormat(path)
+	if err != nil {
+		return err
+	}
+	file, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := newRecordReader(file, format, nil)
+	if err != nil {
+		return err
+	}
+	err = reader.require("block_height", "block_hash")
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		blockHeight, blockHash, err := reader.blockHash(record)
+		if err != nil {
+			return fmt.Errorf("invalid record at offset %d: %w", record.offset, err)
+		}
+		g.blockHashes[blockHeight] = blockHash
+		g.latestBlockHeight = max(g.latestBlockHeight, blockHeight)
+	}
+	if len(g.blockHashes) == 0 {
+		return errors.New("no block hash is recorded")
+	}
+	return nil
+}
+
+func (g *FileOrdGetter) indexTransfers(path string) error {
+	format, err := fileFormat(path)
+	if err != nil {
+		return err
+	}
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	compressed, err := isGzipped(source)
+	if err != nil {
+		source.Close()
+		return err
+	}
+	var content io.Reader = bufio.NewReaderSize(source, 1<<20)
+	var spill *spillWriter
+	if compressed {
+		defer source.Close()
+		content, err = gzip.NewReader(content)
+		if err != nil {
+			return err
+		}
+		spill, err = newSpillWriter()
+		if err != nil {
+			return err
+		}
+		g.file, g.spill = spill.file, !spill.unlinked
+	} else {
+		g.file = source
+	}
+	reader, err := newRecordReader(content, format, nil)
+	if err != nil {
+		return err
+	}
+	err = reader.require("id", "inscription_id", "block_height")
+	if err != nil {
+		return err
+	}
+	g.format = format
+	g.columns = reader.columns
+	g.segments = make(map[uint][]fileSegment)
+
+	for {
+		record, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		blockHeight, err := reader.blockHeight(record)
+		if err != nil {
+			return fmt.Errorf("invalid record at offset %d: %w", record.offset, err)
+		}
+		offset, length := record.offset, record.length
+		if spill != nil {
+			offset, length, err = spill.write(record)
+			if err != nil {
+				return fmt.Errorf("failed to spill the record at offset %d: %w", record.offset, err)
+			}
+		}
+		// The consecutive records of a block are merged into one segment.
+		segments := g.segments[blockHeight]
+		if n := len(segments); n > 0 && segments[n-1].offset+segments[n-1].length == offset {
+			segments[n-1].length += length
+		} else {
+			g.segments[blockHeight] = append(segments, fileSegment{offset: offset, length: length})
+		}
+	}
+	if spill != nil {
+		err = spill.finish()
+		if err != nil {
+			return fmt.Errorf("failed to spill the transfers: %w", err)
+		}
+		g.members = spill.members
+	}
+	return nil
+}
+
+// segmentReader returns the content of the segment. The spilled segment is decompressed from the start of the member
+// it starts in, through the next members if it spans them.
+func (g *FileOrdGetter) segmentReader(segment fileSegment) (io.Reader, error) {
+	if g.members == nil {
+		return io.NewSectionReader(g.file, segment.offset, segment.length), nil
+	}
+	i := sort.Search(len(g.members), func(i int) bool {
+		return g.members[i].start > segment.offset
+	}) - 1
+	member := g.members[i]
+	reader, err := gzip.NewReader(bufio.NewReader(io.NewSectionReader(g.file, member.offset, math.MaxInt64-member.offset)))
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.CopyN(io.Discard, reader, segment.offset-member.start)
+	if err != nil {
+		return nil, err
+	}
+	return io.LimitReader(reader, segment.length), nil
+}
+
+// Close closes the indexed file of the transfers, the spill file is removed.
+func (g *FileOrdGetter) Close() error {
+	if g.file == nil {
+		return nil
+	}
+	err := g.file.Close()
+	if g.spill {
+		err = errors.Join(err, os.Remove(g.file.Name()))
+	}
+	return err
+}
+
+func (g *FileOrdGetter) GetLatestBlockHeight() (uint, error) {
+	return g.latestBlockHeight, nil
+}
+
+func (g *FileOrdGetter) GetBlockHash(blockHeight uint) (string, error) {
+	if blockHash, found := g.blockHashes[blockHeight]; found {
+		return blockHash, nil
+	}
+	return "", fmt.Errorf("%w: height %d", ErrBlockNotFound, blockHeight)
+}
+
+// GetOrdTransfers reads the transfers of a recorded block, the file is read at the indexed offsets
+// so it is safe for concurrent use.
+func (g *FileOrdGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	if _, found := g.blockHashes[blockHeight]; !found {
+		return nil, fmt.Errorf("%w: height %d", ErrBlockNotFound, blockHeight)
+	}
+	ordTransfers := make([]OrdTransfer, 0)
+	for _, segment := range g.segments[blockHeight] {
+		section, err := g.segmentReader(segment)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := newRecordReader(section, g.format, g.columns)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			record, err := reader.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			ordTransfer, err := reader.ordTransfer(record)
+			if err != nil {
+				return nil, fmt.Errorf("invalid record of the block %d: %w", blockHeight, err)
+			}
+			ordTransfers = append(ordTransfers, ordTransfer)
+		}
+	}
+	return ordTransfers, nil
+}
+
+func (g *FileOrdGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	if toHeight < fromHeight {
+		return nil, fmt.Errorf("invalid block range: %d to %d", fromHeight, toHeight)
+	}
+	blocks := make([][]OrdTransfer, 0, toHeight-fromHeight+1)
+	for blockHeight := fromHeight; blockHeight <= toHeight; blockHeight++ {
+		ordTransfers, err := g.GetOrdTransfers(blockHeight)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, ordTransfers)
+	}
+	return blocks, nil
+}
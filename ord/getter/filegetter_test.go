@@ -0,0 +1,159 @@
+package getter
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if !strings.HasSuffix(path, ".gz") {
+		_, err = file.WriteString(content)
+	} else {
+		w := gzip.NewWriter(file)
+		_, err = w.Write([]byte(content))
+		if err == nil {
+			err = w.Close()
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeGzipMembers compresses every size bytes of the content in its own gzip member like bgzip,
+// so the records span the members.
+func writeGzipMembers(t *testing.T, path string, content string, size int) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for i := 0; i < len(content); i += size {
+		w := gzip.NewWriter(file)
+		if _, err := w.Write([]byte(content[i:min(i+size, len(content))])); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileOrdGetter(t *testing.T) {
+	dir := t.TempDir()
+	// The blocks 10 and 11 are interleaved, so the block 10 has two segments.
+	transfers := []OrdTransfer{
+		{ID: 1, InscriptionID: "ai0", BlockHeight: 10, NewSatpoint: "a:0:0", NewPkscript: "5120aa", NewWallet: "bc1pa",
+			Content: []byte(`{"p": "brc-20", "op": "deploy", "tick": "ordi", "max": "21000000"}`), ContentType: "746578742f706c61696e"},
+		{ID: 2, InscriptionID: "bi0", BlockHeight: 11, OldSatpoint: "b:0:0", NewSatpoint: "c:1:0", SentAsFee: true,
+			Content: []byte(`{"p": "brc-20", "op": "transfer", "tick": "ordi", "amt": "1"}`), ContentType: "text/plain", ParentID: "ai0"},
+		{ID: 3, InscriptionID: "di0", BlockHeight: 10, NewSatpoint: "d:0:0", NewPkscript: "0014dd", NewWallet: "bc1qd",
+			Content: []byte("{\"p\": \"brc-20\",\n\"op\": \"mint\", \"tick\": \"ordi\", \"amt\": \"1\"}"), ContentType: "text/plain"},
+	}
+
+	var hashesCSV, transfersCSV, hashesJSONL, transfersJSONL strings.Builder
+	hashesCSV.WriteString("\"block_height\",\"block_hash\"\n")
+	for height := uint(9); height <= 12; height++ {
+		fmt.Fprintf(&hashesCSV, "%d,\"hash%d\"\n", height, height)
+		fmt.Fprintf(&hashesJSONL, "{\"block_height\": %d, \"block_hash\": \"hash%d\"}\n\n", height, height)
+	}
+	transfersCSV.WriteString("\"id\",\"inscription_id\",\"block_height\",\"old_satpoint\",\"new_satpoint\",\"new_pkscript\",\"new_wallet\",\"sent_as_fee\",\"content\",\"content_type\",\"parent_id\"\n")
+	for _, ot := range transfers {
+		fmt.Fprintf(&transfersCSV, "\"%d\",\"%s\",%d,\"%s\",\"%s\",\"%s\",\"%s\",%s,\"%s\",\"%s\",\"%s\"\n",
+			ot.ID, ot.InscriptionID, ot.BlockHeight, ot.OldSatpoint, ot.NewSatpoint, ot.NewPkscript, ot.NewWallet,
+			map[bool]string{false: "False", true: "True"}[ot.SentAsFee], strings.ReplaceAll(string(ot.Content), `"`, `""`), ot.ContentType, ot.ParentID)
+		line, err := json.Marshal(map[string]any{
+			"id": ot.ID, "inscription_id": ot.InscriptionID, "block_height": ot.BlockHeight, "old_satpoint": ot.OldSatpoint,
+			"new_satpoint": ot.NewSatpoint, "new_pkscript": ot.NewPkscript, "new_wallet": ot.NewWallet, "sent_as_fee": ot.SentAsFee,
+			"content": string(ot.Content), "content_type": ot.ContentType, "parent_id": ot.ParentID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		transfersJSONL.Write(append(line, '\n'))
+	}
+	writeFile(t, filepath.Join(dir, "hashes.csv"), hashesCSV.String())
+	writeFile(t, filepath.Join(dir, "transfers.csv"), transfersCSV.String())
+	writeFile(t, filepath.Join(dir, "hashes.jsonl.gz"), hashesJSONL.String())
+	writeFile(t, filepath.Join(dir, "transfers.jsonl.gz"), transfersJSONL.String())
+	writeFile(t, filepath.Join(dir, "transfers.jsonl"), transfersJSONL.String())
+	writeGzipMembers(t, filepath.Join(dir, "transfers.csv.gz"), transfersCSV.String(), 50)
+
+	expected := [][]OrdTransfer{{}, {transfers[0], transfers[2]}, {transfers[1]}, {}}
+	for _, paths := range [][2]string{
+		{"hashes.csv", "transfers.csv"},
+		{"hashes.jsonl.gz", "transfers.jsonl.gz"},
+		{"hashes.csv", "transfers.jsonl"},
+		{"hashes.csv", "transfers.csv.gz"},
+	} {
+		g, err := NewFileOrdGetter(filepath.Join(dir, paths[0]), filepath.Join(dir, paths[1]))
+		if err != nil {
+			t.Fatal(paths, err)
+		}
+		if latest, err := g.GetLatestBlockHeight(); err != nil || latest != 12 {
+			t.Fatal(paths, "unexpected latest block height", latest, err)
+		}
+		if hash, err := g.GetBlockHash(11); err != nil || hash != "hash11" {
+			t.Fatal(paths, "unexpected block hash", hash, err)
+		}
+		blocks, err := g.GetOrdTransfersInRange(9, 12)
+		if err != nil {
+			t.Fatal(paths, err)
+		}
+		if !reflect.DeepEqual(blocks, expected) {
+			t.Fatalf("%v: unexpected transfers:\n%+v\n%+v", paths, blocks, expected)
+		}
+		if _, err := g.GetOrdTransfers(13); !errors.Is(err, ErrBlockNotFound) {
+			t.Fatal(paths, "unexpected error of the missing block", err)
+		}
+		if err := g.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := NewFileOrdGetter(filepath.Join(dir, "hashes.csv"), filepath.Join(dir, "transfers.txt")); err == nil {
+		t.Fatal("the unknown format is accepted")
+	}
+
+	// A plain gzip file is spilled into small gzip members, which are removed with the getter.
+	size := spillMemberSize
+	spillMemberSize = 100
+	defer func() { spillMemberSize = size }()
+	for _, path := range []string{"transfers.jsonl.gz", "transfers.csv.gz"} {
+		g, err := NewFileOrdGetter(filepath.Join(dir, "hashes.csv"), filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(path, err)
+		}
+		if len(g.members) < 3 {
+			t.Fatal(path, "unexpected gzip members", g.members)
+		}
+		blocks, err := g.GetOrdTransfersInRange(9, 12)
+		if err != nil {
+			t.Fatal(path, err)
+		}
+		if !reflect.DeepEqual(blocks, expected) {
+			t.Fatalf("%v: unexpected transfers:\n%+v\n%+v", path, blocks, expected)
+		}
+		spill := g.file.Name()
+		if err := g.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(spill); !os.IsNotExist(err) {
+			t.Fatal(path, "the spill file is not removed")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package getter
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// The Postgres channel notified by the trigger on block_hashes, see the README for the trigger.
+const NewBlockChannel = "opi_new_block"
+
+// The longest wait before reconnecting the lost notification connection.
+const maxReconnectBackoff = time.Minute
+
+// SubscribeNewBlocks listens to the Postgres channel on a dedicated connection.
+// The connection is reopened with backoff if it is lost, and the subscriber is signaled once it is back
+// since the notifications in between are missed.
+func (opi *OPIOrdGetter) SubscribeNewBlocks(ctx context.Context, channel string) (<-chan struct{}, error) {
+	return subscribe(ctx, channel, func(ctx context.Context) (listener, error) {
+		return opi.listen(ctx, channel)
+	}, time.Second)
+}
+
+// listener is a connection listening to the channel, i.e. *pgx.Conn after LISTEN.
+type listener interface {
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// subscribe signals the returned channel on every notification of the listener opened by listen.
+// The lost listener is reopened after minBackoff, doubled after each failure up to maxReconnectBackoff.
+func subscribe(ctx context.Context, channel string, listen func(ctx context.Context) (listener, error), minBackoff time.Duration) (<-chan struct{}, error) {
+	conn, err := listen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	notified := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case notified <- struct{}{}:
+		default:
+		}
+	}
+	go func() {
+		defer func() {
+			if conn != nil {
+				_ = conn.Close(context.Background())
+			}
+		}()
+		backoff := minBackoff
+		for {
+			_, err := conn.WaitForNotification(ctx)
+			if err == nil {
+				notify()
+				continue
+			}
+			_ = conn.Close(context.Background())
+			conn = nil
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Lost the notification channel %s: %v", channel, err)
+			for conn == nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				conn, err = listen(ctx)
+				if err != nil {
+					conn = nil
+					log.Printf("Failed to listen to the notification channel %s: %v", channel, err)
+					backoff = min(2*backoff, maxReconnectBackoff)
+				}
+			}
+			backoff = minBackoff
+			log.Printf("Reconnected to the notification channel %s", channel)
+			notify()
+		}
+	}()
+	return notified, nil
+}
+
+func (opi *OPIOrdGetter) listen(ctx context.Context, channel string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, opi.dsn)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	if err != nil {
+		_ = conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
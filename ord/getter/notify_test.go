@@ -0,0 +1,121 @@
+package getter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeListener delivers a notification for every nil event, and loses the connection on an error.
+type fakeListener struct {
+	events chan error
+	closed atomic.Bool
+}
+
+func newFakeListener() *fakeListener {
+	return &fakeListener{events: make(chan error)}
+}
+
+func (l *fakeListener) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case err := <-l.events:
+		if err != nil {
+			return nil, err
+		}
+		return &pgconn.Notification{Channel: NewBlockChannel}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *fakeListener) Close(ctx context.Context) error {
+	l.closed.Store(true)
+	return nil
+}
+
+// fakeDatabase opens the scripted listeners in order, a nil listener fails the attempt.
+type fakeDatabase struct {
+	sync.Mutex
+	listeners []*fakeListener
+	attempts  []time.Time
+}
+
+func (d *fakeDatabase) listen(ctx context.Context) (listener, error) {
+	d.Lock()
+	defer d.Unlock()
+	d.attempts = append(d.attempts, time.Now())
+	if len(d.listeners) == 0 || d.listeners[0] == nil {
+		if len(d.listeners) > 0 {
+			d.listeners = d.listeners[1:]
+		}
+		return nil, errors.New("connection refused")
+	}
+	l := d.listeners[0]
+	d.listeners = d.listeners[1:]
+	return l, nil
+}
+
+func expectSignal(t *testing.T, notified <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not signaled:", what)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	// The subscription fails if the channel can't be listened, the caller falls back to polling.
+	if _, err := subscribe(context.Background(), NewBlockChannel, (&fakeDatabase{}).listen, time.Millisecond); err == nil {
+		t.Fatal("subscribed without the listener")
+	}
+
+	first, second := newFakeListener(), newFakeListener()
+	db := &fakeDatabase{listeners: []*fakeListener{first, nil, nil, second}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const backoff = 20 * time.Millisecond
+	notified, err := subscribe(ctx, NewBlockChannel, db.listen, backoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.events <- nil
+	expectSignal(t, notified, "the notification")
+
+	// The lost connection is reopened after two failed attempts, then the missed blocks are signaled.
+	first.events <- errors.New("connection reset")
+	expectSignal(t, notified, "the reconnection")
+	if !first.closed.Load() {
+		t.Fatal("the lost listener is not closed")
+	}
+	db.Lock()
+	attempts := db.attempts
+	db.Unlock()
+	if len(attempts) != 4 {
+		t.Fatal("unexpected attempts to listen", len(attempts))
+	}
+	// The backoff doubles after each failure.
+	for i, wait := range []time.Duration{2 * backoff, 4 * backoff} {
+		if gap := attempts[i+2].Sub(attempts[i+1]); gap < wait {
+			t.Fatalf("attempt %d after %v, expected the backoff of %v", i+2, gap, wait)
+		}
+	}
+
+	second.events <- nil
+	expectSignal(t, notified, "the notification after the reconnection")
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for !second.closed.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("the listener is not closed with the context")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
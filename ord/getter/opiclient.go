@@ -1,6 +1,8 @@
 package getter
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -20,16 +22,16 @@ type DatabaseConfig struct {
 
 type OPIOrdGetter struct {
 	db *gorm.DB
+	// The DSN of the database, the notifications are received by a dedicated connection.
+	dsn string
+}
+
+func (config *DatabaseConfig) dsn() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s", config.Host, config.User, config.Password, config.DBname, config.Port)
 }
 
 func ConnectOPIDatabase(config *DatabaseConfig) (*gorm.DB, error) {
-	host := config.Host
-	user := config.User
-	password := config.Password
-	dbname := config.DBname
-	port := config.Port
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s", host, user, password, dbname, port)
-	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	return gorm.Open(postgres.Open(config.dsn()), &gorm.Config{})
 }
 
 func NewOPIOrdGetter(config *DatabaseConfig) (*OPIOrdGetter, error) {
@@ -38,11 +40,20 @@ func NewOPIOrdGetter(config *DatabaseConfig) (*OPIOrdGetter, error) {
 		return nil, err
 	}
 	getter := OPIOrdGetter{
-		db: db,
+		db:  db,
+		dsn: config.dsn(),
 	}
 	return &getter, err
 }
 
+// WithContext returns a getter whose queries are bound to ctx, it shares the connections with opi.
+func (opi *OPIOrdGetter) WithContext(ctx context.Context) OrdGetter {
+	return &OPIOrdGetter{
+		db:  opi.db.WithContext(ctx),
+		dsn: opi.dsn,
+	}
+}
+
 func (opi *OPIOrdGetter) GetLatestBlockHeight() (uint, error) {
 	defer metrics.ObserveDBQuery("getLatestBlockHeight", time.Now())
 
@@ -67,9 +78,12 @@ func (opi *OPIOrdGetter) GetBlockHash(blockHeight uint) (string, error) {
 		FROM block_hashes
 		WHERE block_height = $1
 	`
-	err := opi.db.Raw(sql, blockHeight).Scan(&blockHash).Error
-	if err != nil {
-		return "", err
+	result := opi.db.Raw(sql, blockHeight).Scan(&blockHash)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 || blockHash == "" {
+		return "", fmt.Errorf("%w: height %d", ErrBlockNotFound, blockHeight)
 	}
 	return blockHash, nil
 }
@@ -94,3 +108,78 @@ func (opi *OPIOrdGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error
 	}
 	return ordTransfers, nil
 }
+
+// The brc-20 transfers of the blocks in a range, ordered by the block height and the id.
+const ordTransfersInRangeSQL = `
+	SELECT ot.id, ot.inscription_id, ot.block_height, ot.old_satpoint, ot.new_satpoint, ot.new_pkscript, ot.new_wallet, ot.sent_as_fee, oc."content", oc.content_type, onti.parent_id
+		FROM ord_transfers ot
+		LEFT JOIN ord_content oc ON ot.inscription_id = oc.inscription_id
+		LEFT JOIN ord_number_to_id onti ON ot.inscription_id = onti.inscription_id
+		WHERE ot.block_height BETWEEN $1 AND $2
+			AND onti.cursed_for_brc20 = false
+			AND oc."content" is not null AND oc."content"->>'p' = 'brc-20'
+		ORDER BY ot.block_height asc, ot.id asc;
+		`
+
+func queryOrdTransfersInRange(db *gorm.DB, fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	if toHeight < fromHeight {
+		return nil, fmt.Errorf("invalid block range: %d to %d", fromHeight, toHeight)
+	}
+	var ordTransfers []OrdTransfer
+	err := db.Raw(ordTransfersInRangeSQL, fromHeight, toHeight).Scan(&ordTransfers).Error
+	if err != nil {
+		return nil, err
+	}
+	return groupByBlock(ordTransfers, fromHeight, toHeight)
+}
+
+func (opi *OPIOrdGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	defer metrics.ObserveDBQuery("getOrdTransfersInRange", time.Now())
+
+	return queryOrdTransfersInRange(opi.db, fromHeight, toHeight)
+}
+
+// GetBlockView reads the hashes and the transfers of the blocks in one REPEATABLE READ, read-only transaction,
+// so all of them come from the same snapshot of OPI even if OPI rolls back the blocks meanwhile.
+func (opi *OPIOrdGetter) GetBlockView(fromHeight uint, toHeight uint) (*BlockView, error) {
+	defer metrics.ObserveDBQuery("getBlockView", time.Now())
+
+	if toHeight < fromHeight || fromHeight == 0 {
+		return nil, fmt.Errorf("invalid block range: %d to %d", fromHeight, toHeight)
+	}
+	view := BlockView{
+		FromHeight: fromHeight,
+		ToHeight:   toHeight,
+	}
+	err := opi.db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			BlockHeight uint
+			BlockHash   string
+		}
+		sql := `
+			SELECT block_height, block_hash
+			FROM block_hashes
+			WHERE block_height BETWEEN $1 AND $2
+			ORDER BY block_height asc
+		`
+		err := tx.Raw(sql, fromHeight-1, toHeight).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		for i, row := range rows {
+			if row.BlockHeight != fromHeight-1+uint(i) {
+				return fmt.Errorf("%w: height %d", ErrBlockNotFound, fromHeight-1+uint(i))
+			}
+			view.Hashes = append(view.Hashes, row.BlockHash)
+		}
+		if len(rows) != int(toHeight-fromHeight+2) {
+			return fmt.Errorf("%w: height %d", ErrBlockNotFound, fromHeight-1+uint(len(rows)))
+		}
+		view.Transfers, err = queryOrdTransfersInRange(tx, fromHeight, toHeight)
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 
 	"github.com/RiemaLabs/modular-indexer-committee/ord"
@@ -102,7 +103,7 @@ func (opi *OPIOrdGetterTest) GetBlockHash(blockHeight uint) (string, error) {
 	if result, found := opi.BlockHash[blockHeight]; found {
 		return result, nil
 	}
-	return "", fmt.Errorf("block hash not found for block height: %d", blockHeight)
+	return "", fmt.Errorf("%w: height %d", ErrBlockNotFound, blockHeight)
 }
 
 func (opi *OPIOrdGetterTest) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
@@ -115,3 +116,20 @@ func (opi *OPIOrdGetterTest) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, e
 	}
 	return filteredOrdTransfers, nil
 }
+
+func (opi *OPIOrdGetterTest) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	if toHeight < fromHeight {
+		return nil, fmt.Errorf("invalid block range: %d to %d", fromHeight, toHeight)
+	}
+	var filteredOrdTransfers []OrdTransfer
+	for _, transfer := range opi.OrdTransfers {
+		if transfer.BlockHeight >= fromHeight && transfer.BlockHeight <= toHeight {
+			filteredOrdTransfers = append(filteredOrdTransfers, transfer)
+		}
+	}
+	// The transfers of a block keep their order in the file.
+	sort.SliceStable(filteredOrdTransfers, func(i, j int) bool {
+		return filteredOrdTransfers[i].BlockHeight < filteredOrdTransfers[j].BlockHeight
+	})
+	return groupByBlock(filteredOrdTransfers, fromHeight, toHeight)
+}
@@ -0,0 +1,717 @@
+package getter
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/RiemaLabs/modular-indexer-committee/internal/metrics"
+	"github.com/RiemaLabs/modular-indexer-committee/ord"
+)
+
+// The number of inscriptions whose metadata and content are cached, they never change once inscribed.
+const inscriptionCacheSize = 65536
+
+// The txid of the null outpoint, ord moves the inscriptions lost as fees to it.
+var nullTxID = strings.Repeat("0", 64)
+
+// errOrdNotFound is returned if ord answers 404 Not Found.
+var errOrdNotFound = errors.New("not found by ord")
+
+// The number of outputs queried at once from ord.
+const outputQueries = 16
+
+// OrdHTTPGetter reads the blocks from the JSON API of a stock ord server, started with `ord server` and queried
+// with the `Accept: application/json` header. The endpoints used are:
+//
+//	GET /blockheight            the latest indexed block height
+//	GET /r/blockhash/{height}   the hash of a block
+//	GET /block/{height}         a block with its transactions and the inscriptions inscribed in it
+//	GET /output/{outpoint}      the value of an output and the inscriptions on it while it is unspent
+//	GET /inscription/{id}       the metadata of an inscription and its current satpoint
+//	GET /content/{id}           the content of an inscription
+//
+// ord only serves the current locations of the inscriptions, so the transfers of a block are derived from the
+// inscriptions still on its outputs: each one is traced back through the sat flow of the block to where it entered
+// the block, either an output of an earlier block or its reveal transaction. The inscriptions of the block not on
+// its outputs anymore are placed by the sat flow of their reveal transaction from its first input, where ord puts
+// them without a pointer. The transfers match the ord_transfers table of OPI if every output of the block holding a
+// brc-20 inscription is unspent when the block is read, i.e. the block is read close to the tip, except the ID which
+// is the position in the block. The inscriptions moved again in a later block and the ones lost with the fees not
+// claimed by the coinbase are missed, the blocks deeper than that must be read from OPI or the recorded files.
+type OrdHTTPGetter struct {
+	ctx    context.Context
+	url    string
+	client *http.Client
+	// The inscriptions by ID, shared with the getters returned by WithContext.
+	inscriptions *lru.Cache[string, *ordInscription]
+}
+
+func NewOrdHTTPGetter(url string, client *http.Client) (*OrdHTTPGetter, error) {
+	if url == "" {
+		return nil, errors.New("the URL of the ord server is empty")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	inscriptions, err := lru.New[string, *ordInscription](inscriptionCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	getter := OrdHTTPGetter{
+		ctx:          context.Background(),
+		url:          strings.TrimSuffix(url, "/"),
+		client:       client,
+		inscriptions: inscriptions,
+	}
+	return &getter, nil
+}
+
+// WithContext returns a getter whose requests are bound to ctx, it shares the client and the cache with g.
+func (g *OrdHTTPGetter) WithContext(ctx context.Context) OrdGetter {
+	getter := *g
+	getter.ctx = ctx
+	return &getter
+}
+
+func (g *OrdHTTPGetter) get(path string, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(g.ctx, http.MethodGet, g.url+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", errOrdNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status of %s: %s %s", path, resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
+
+func (g *OrdHTTPGetter) getJSON(path string, v any) error {
+	body, err := g.get(path, "application/json")
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+func (g *OrdHTTPGetter) GetLatestBlockHeight() (uint, error) {
+	defer metrics.ObserveDBQuery("getLatestBlockHeight", time.Now())
+
+	var blockHeight uint
+	err := g.getJSON("/blockheight", &blockHeight)
+	if err != nil {
+		return 0, err
+	}
+	return blockHeight, nil
+}
+
+func (g *OrdHTTPGetter) GetBlockHash(blockHeight uint) (string, error) {
+	defer metrics.ObserveDBQuery("getBlockHash", time.Now())
+
+	var blockHash string
+	err := g.getJSON(fmt.Sprintf("/r/blockhash/%d", blockHeight), &blockHash)
+	if errors.Is(err, errOrdNotFound) {
+		return "", fmt.Errorf("%w: height %d", ErrBlockNotFound, blockHeight)
+	}
+	if err != nil {
+		return "", err
+	}
+	return blockHash, nil
+}
+
+func (g *OrdHTTPGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	defer metrics.ObserveDBQuery("getOrdTransfers", time.Now())
+
+	return g.getOrdTransfers(blockHeight)
+}
+
+func (g *OrdHTTPGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	defer metrics.ObserveDBQuery("getOrdTransfersInRange", time.Now())
+
+	if toHeight < fromHeight {
+		return nil, fmt.Errorf("invalid block range: %d to %d", fromHeight, toHeight)
+	}
+	blocks := make([][]OrdTransfer, 0, toHeight-fromHeight+1)
+	for blockHeight := fromHeight; blockHeight <= toHeight; blockHeight++ {
+		ordTransfers, err := g.getOrdTransfers(blockHeight)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, ordTransfers)
+	}
+	return blocks, nil
+}
+
+// A transaction in the serialization of rust-bitcoin.
+type ordTransaction struct {
+	Version  int32  `json:"version"`
+	LockTime uint32 `json:"lock_time"`
+	Input    []struct {
+		PreviousOutput string   `json:"previous_output"`
+		ScriptSig      string   `json:"script_sig"`
+		Sequence       uint32   `json:"sequence"`
+		Witness        []string `json:"witness"`
+	} `json:"input"`
+	Output []struct {
+		Value        int64  `json:"value"`
+		ScriptPubkey string `json:"script_pubkey"`
+	} `json:"output"`
+}
+
+// A block as returned by GET /block/{height}, the inscriptions are the ones inscribed in the block.
+type ordBlock struct {
+	Hash         string           `json:"hash"`
+	Height       uint             `json:"height"`
+	Inscriptions []string         `json:"inscriptions"`
+	Transactions []ordTransaction `json:"transactions"`
+}
+
+// An output as returned by GET /output/{outpoint}, the inscriptions are only listed while it is unspent.
+type ordOutput struct {
+	Inscriptions []string `json:"inscriptions"`
+	Spent        bool     `json:"spent"`
+	Value        int64    `json:"value"`
+}
+
+// A transaction of a block with the previous outputs of its inputs.
+type blockTx struct {
+	txid    string
+	inputs  []string
+	values  []int64
+	scripts [][]byte
+}
+
+func (tx *blockTx) outputValue() int64 {
+	var total int64
+	for _, value := range tx.values {
+		total += value
+	}
+	return total
+}
+
+// transactions computes the txids of the transactions of the block.
+func (b *ordBlock) transactions() ([]blockTx, error) {
+	txs := make([]blockTx, 0, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		msgTx := wire.NewMsgTx(tx.Version)
+		msgTx.LockTime = tx.LockTime
+		inputs := make([]string, 0, len(tx.Input))
+		for _, input := range tx.Input {
+			txid, vout, found := strings.Cut(input.PreviousOutput, ":")
+			hash, err := chainhash.NewHashFromStr(txid)
+			if !found || err != nil {
+				return nil, fmt.Errorf("invalid previous output %q in block %d", input.PreviousOutput, b.Height)
+			}
+			index, err := strconv.ParseUint(vout, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid previous output %q in block %d", input.PreviousOutput, b.Height)
+			}
+			scriptSig, err := hex.DecodeString(input.ScriptSig)
+			if err != nil {
+				return nil, fmt.Errorf("invalid script_sig in block %d: %w", b.Height, err)
+			}
+			txIn := wire.NewTxIn(wire.NewOutPoint(hash, uint32(index)), scriptSig, nil)
+			txIn.Sequence = input.Sequence
+			msgTx.AddTxIn(txIn)
+			inputs = append(inputs, input.PreviousOutput)
+		}
+		values := make([]int64, 0, len(tx.Output))
+		scripts := make([][]byte, 0, len(tx.Output))
+		for _, output := range tx.Output {
+			script, err := hex.DecodeString(output.ScriptPubkey)
+			if err != nil {
+				return nil, fmt.Errorf("invalid script_pubkey in block %d: %w", b.Height, err)
+			}
+			msgTx.AddTxOut(wire.NewTxOut(output.Value, script))
+			values = append(values, output.Value)
+			scripts = append(scripts, script)
+		}
+		// The txid commits to the transaction without the witnesses.
+		txs = append(txs, blockTx{txid: msgTx.TxHash().String(), inputs: inputs, values: values, scripts: scripts})
+	}
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("no coinbase in block %d", b.Height)
+	}
+	return txs, nil
+}
+
+// The metadata of an inscription, as returned by GET /inscription/{id}, with the content of the brc-20 ones.
+type ordInscription struct {
+	ID          string   `json:"id"`
+	Number      int64    `json:"number"`
+	ContentType string   `json:"content_type"`
+	Charms      []string `json:"charms"`
+	// The parents since ord 0.19, and the parent before.
+	Parents []string `json:"parents"`
+	Parent  string   `json:"parent"`
+	// The current satpoint, it is stale once cached.
+	Satpoint string `json:"satpoint"`
+
+	brc20   bool
+	content []byte
+}
+
+// cursed reports whether the inscription is cursed for brc-20, the vindicated inscriptions stay cursed.
+func (i *ordInscription) cursed() bool {
+	if i.Number < 0 {
+		return true
+	}
+	for _, charm := range i.Charms {
+		if charm == "cursed" || charm == "vindicated" {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *ordInscription) parentID() string {
+	if len(i.Parents) > 0 {
+		return i.Parents[0]
+	}
+	return i.Parent
+}
+
+// isBRC20 reports whether the content is a JSON object whose "p" is "brc-20", like the filter of OPI.
+func isBRC20(content []byte) bool {
+	var js map[string]any
+	if json.Unmarshal(content, &js) != nil {
+		return false
+	}
+	p, ok := js["p"].(string)
+	return ok && p == "brc-20"
+}
+
+func (g *OrdHTTPGetter) getInscription(inscriptionID string) (*ordInscription, error) {
+	if inscription, ok := g.inscriptions.Get(inscriptionID); ok {
+		return inscription, nil
+	}
+	var inscription ordInscription
+	err := g.getJSON("/inscription/"+inscriptionID, &inscription)
+	if err != nil {
+		return nil, err
+	}
+	if !inscription.cursed() {
+		content, err := g.get("/content/"+inscriptionID, "*/*")
+		if err != nil && !errors.Is(err, errOrdNotFound) {
+			return nil, err
+		}
+		// The inscriptions without content are kept as not brc-20.
+		if err == nil && isBRC20(content) {
+			inscription.brc20 = true
+			inscription.content = content
+		}
+	}
+	g.inscriptions.Add(inscriptionID, &inscription)
+	return &inscription, nil
+}
+
+// wallet returns the address of the script on the mainnet like the new_wallet of OPI, which has an address only
+// for the scripts of the address types, e.g. not for the pay-to-pubkey and the bare multisig scripts.
+func wallet(script []byte) ord.Wallet {
+	class, addresses, _, err := txscript.ExtractPkScriptAddrs(script, &chaincfg.MainNetParams)
+	if err != nil || len(addresses) != 1 {
+		return ""
+	}
+	switch class {
+	case txscript.PubKeyHashTy, txscript.ScriptHashTy, txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy,
+		txscript.WitnessV1TaprootTy:
+		return ord.Wallet(addresses[0].EncodeAddress())
+	default:
+		return ""
+	}
+}
+
+// getSatpoint returns the current satpoint of the inscription, bypassing the cache.
+func (g *OrdHTTPGetter) getSatpoint(inscriptionID string) (string, error) {
+	var inscription ordInscription
+	err := g.getJSON("/inscription/"+inscriptionID, &inscription)
+	return inscription.Satpoint, err
+}
+
+func (g *OrdHTTPGetter) getOutput(outpoint string) (*ordOutput, error) {
+	var output ordOutput
+	err := g.getJSON("/output/"+outpoint, &output)
+	if err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// getOutputs queries the outputs concurrently, the results are in the order of the outpoints.
+func (g *OrdHTTPGetter) getOutputs(outpoints []string) ([]*ordOutput, error) {
+	outputs := make([]*ordOutput, len(outpoints))
+	errs := make([]error, len(outpoints))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(outputQueries, len(outpoints)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				outputs[i], errs[i] = g.getOutput(outpoints[i])
+			}
+		}()
+	}
+	for i := range outpoints {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return outputs, errors.Join(errs...)
+}
+
+// subsidy returns the block reward without the fees.
+func subsidy(blockHeight uint) int64 {
+	halvings := blockHeight / 210000
+	if halvings >= 64 {
+		return 0
+	}
+	return 50_0000_0000 >> halvings
+}
+
+// A transfer of the block with the position ord applies it at: the transactions in order except the coinbase,
+// which takes the fees last, then the offset of the new satpoint in the outputs.
+type blockTransfer struct {
+	OrdTransfer
+	tx     int
+	offset int64
+}
+
+// blockTracer follows the sat flow of a block.
+type blockTracer struct {
+	g           *OrdHTTPGetter
+	blockHeight uint
+	txs         []blockTx
+	// The position of the transactions by txid.
+	positions map[string]int
+	// The values of the previous outputs from the earlier blocks.
+	values map[string]int64
+	// The fees of the transactions in order, computed once needed.
+	fees []int64
+}
+
+func splitOutpoint(outpoint string) (string, int, error) {
+	txid, vout, found := strings.Cut(outpoint, ":")
+	index, err := strconv.Atoi(vout)
+	if !found || err != nil {
+		return "", 0, fmt.Errorf("invalid outpoint %q", outpoint)
+	}
+	return txid, index, nil
+}
+
+// inputValues returns the values of the previous outputs of the transaction.
+func (b *blockTracer) inputValues(t int) ([]int64, error) {
+	tx := &b.txs[t]
+	values := make([]int64, len(tx.inputs))
+	missing := make([]string, 0)
+	for i, outpoint := range tx.inputs {
+		txid, vout, err := splitOutpoint(outpoint)
+		if err != nil {
+			return nil, err
+		}
+		if position, found := b.positions[txid]; found && vout < len(b.txs[position].values) {
+			values[i] = b.txs[position].values[vout]
+		} else if value, found := b.values[outpoint]; found {
+			values[i] = value
+		} else {
+			missing = append(missing, outpoint)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	outputs, err := b.g.getOutputs(missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, outpoint := range missing {
+		b.values[outpoint] = outputs[i].Value
+	}
+	return b.inputValues(t)
+}
+
+// fee returns the fee of the transaction, the sats of its inputs beyond its outputs.
+func (b *blockTracer) fee(t int) (int64, error) {
+	for len(b.fees) <= t {
+		k := len(b.fees)
+		if k == 0 {
+			b.fees = append(b.fees, 0)
+			continue
+		}
+		values, err := b.inputValues(k)
+		if err != nil {
+			return 0, err
+		}
+		var input int64
+		for _, value := range values {
+			input += value
+		}
+		b.fees = append(b.fees, input-b.txs[k].outputValue())
+	}
+	return b.fees[t], nil
+}
+
+// satpoint returns the satpoint at the offset in the outputs of the transaction and the script holding it,
+// the offset beyond the outputs of the coinbase is lost to the null outpoint.
+func (b *blockTracer) satpoint(t int, offset int64) (string, []byte) {
+	tx := &b.txs[t]
+	for vout, value := range tx.values {
+		if offset < value {
+			return fmt.Sprintf("%s:%d:%d", tx.txid, vout, offset), tx.scripts[vout]
+		}
+		offset -= value
+	}
+	return fmt.Sprintf("%s:0:%d", nullTxID, offset), nil
+}
+
+// feeSource returns the transaction paying the sat at the offset in the outputs of the coinbase, and the offset
+// of the sat in the inputs of the transaction. The coinbase takes the subsidy, then the fees in order.
+func (b *blockTracer) feeSource(offset int64) (int, int64, error) {
+	offset -= subsidy(b.blockHeight)
+	if offset < 0 {
+		return 0, 0, fmt.Errorf("an inscription is on the subsidy of block %d", b.blockHeight)
+	}
+	for t := 1; t < len(b.txs); t++ {
+		fee, err := b.fee(t)
+		if err != nil {
+			return 0, 0, err
+		}
+		if offset < fee {
+			return t, b.txs[t].outputValue() + offset, nil
+		}
+		offset -= fee
+	}
+	return 0, 0, fmt.Errorf("an inscription is beyond the fees of block %d", b.blockHeight)
+}
+
+// land returns where the sat at the offset in the inputs of the transaction goes, its offset in the outputs of
+// the transaction or of the coinbase if it is paid as fee.
+func (b *blockTracer) land(t int, offset int64) (int, int64, error) {
+	outputValue := b.txs[t].outputValue()
+	if t == 0 || offset < outputValue {
+		return t, offset, nil
+	}
+	coinbaseOffset := subsidy(b.blockHeight) + offset - outputValue
+	for k := 1; k < t; k++ {
+		fee, err := b.fee(k)
+		if err != nil {
+			return 0, 0, err
+		}
+		coinbaseOffset += fee
+	}
+	return 0, coinbaseOffset, nil
+}
+
+func (b *blockTracer) transfer(inscription *ordInscription, t int, offset int64, oldSatpoint string, sentAsFee bool) blockTransfer {
+	newSatpoint, script := b.satpoint(t, offset)
+	if t == 0 {
+		// The coinbase takes the fees after the other transactions.
+		t = len(b.txs)
+	}
+	return blockTransfer{
+		OrdTransfer: OrdTransfer{
+			InscriptionID: inscription.ID,
+			BlockHeight:   b.blockHeight,
+			OldSatpoint:   oldSatpoint,
+			NewSatpoint:   newSatpoint,
+			NewPkscript:   ord.Pkscript(hex.EncodeToString(script)),
+			NewWallet:     wallet(script),
+			SentAsFee:     sentAsFee,
+			Content:       inscription.content,
+			ContentType:   inscription.ContentType,
+			ParentID:      inscription.parentID(),
+		},
+		tx:     t,
+		offset: offset,
+	}
+}
+
+// trace follows the inscription back from the offset in the outputs of the transaction t to where it entered the
+// block, it returns the transfers of the inscription in the block from the last one.
+func (b *blockTracer) trace(inscription *ordInscription, inscribed bool, t int, offset int64) ([]blockTransfer, error) {
+	transfers := make([]blockTransfer, 0, 1)
+	revealTxID := inscription.ID[:min(len(inscription.ID), 64)]
+	for {
+		// The source of the transfer, the inputs of the coinbase hold the fees.
+		source, sourceOffset, sentAsFee := t, offset, false
+		if t == 0 {
+			var err error
+			source, sourceOffset, err = b.feeSource(offset)
+			if err != nil {
+				return nil, err
+			}
+			sentAsFee = true
+		}
+		if inscribed && b.txs[source].txid == revealTxID {
+			return append(transfers, b.transfer(inscription, t, offset, "", sentAsFee)), nil
+		}
+		values, err := b.inputValues(source)
+		if err != nil {
+			return nil, err
+		}
+		input := 0
+		for input < len(values) && sourceOffset >= values[input] {
+			sourceOffset -= values[input]
+			input++
+		}
+		if input == len(values) {
+			return nil, fmt.Errorf("the inscription %s is beyond the inputs of %s", inscription.ID, b.txs[source].txid)
+		}
+		outpoint := b.txs[source].inputs[input]
+		transfers = append(transfers, b.transfer(inscription, t, offset, fmt.Sprintf("%s:%d", outpoint, sourceOffset), sentAsFee))
+
+		txid, vout, err := splitOutpoint(outpoint)
+		if err != nil {
+			return nil, err
+		}
+		position, found := b.positions[txid]
+		if !found {
+			return transfers, nil
+		}
+		// The inscription is moved twice in the block.
+		t, offset = position, sourceOffset
+		for _, value := range b.txs[position].values[:vout] {
+			offset += value
+		}
+	}
+}
+
+func (g *OrdHTTPGetter) getOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	var block ordBlock
+	err := g.getJSON(fmt.Sprintf("/block/%d", blockHeight), &block)
+	if errors.Is(err, errOrdNotFound) {
+		return nil, fmt.Errorf("%w: height %d", ErrBlockNotFound, blockHeight)
+	}
+	if err != nil {
+		return nil, err
+	}
+	txs, err := block.transactions()
+	if err != nil {
+		return nil, err
+	}
+	b := blockTracer{
+		g:           g,
+		blockHeight: blockHeight,
+		txs:         txs,
+		positions:   make(map[string]int, len(txs)),
+		values:      make(map[string]int64),
+	}
+	outpoints := make([]string, 0)
+	for position, tx := range txs {
+		b.positions[tx.txid] = position
+		for vout := range tx.values {
+			outpoints = append(outpoints, fmt.Sprintf("%s:%d", tx.txid, vout))
+		}
+	}
+	outputs, err := g.getOutputs(outpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	inscribed := make(map[string]bool, len(block.Inscriptions))
+	for _, inscriptionID := range block.Inscriptions {
+		inscribed[inscriptionID] = true
+	}
+	traced := make(map[string]bool)
+	transfers := make([]blockTransfer, 0)
+	for i, output := range outputs {
+		for _, inscriptionID := range output.Inscriptions {
+			inscription, err := g.getInscription(inscriptionID)
+			if err != nil {
+				return nil, err
+			}
+			if !inscription.brc20 {
+				continue
+			}
+			satpoint, err := g.getSatpoint(inscriptionID)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasPrefix(satpoint, outpoints[i]+":") {
+				return nil, fmt.Errorf("the inscription %s is moved from %s while block %d is read", inscriptionID, outpoints[i], blockHeight)
+			}
+			txid, vout, _ := splitOutpoint(outpoints[i])
+			offset, err := strconv.ParseInt(satpoint[len(outpoints[i])+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid satpoint %q of %s", satpoint, inscriptionID)
+			}
+			t := b.positions[txid]
+			for _, value := range txs[t].values[:vout] {
+				offset += value
+			}
+			traces, err := b.trace(inscription, inscribed[inscriptionID], t, offset)
+			if err != nil {
+				return nil, err
+			}
+			transfers = append(transfers, traces...)
+			traced[inscriptionID] = true
+		}
+	}
+	// The inscriptions moved out of the block since are placed from the first input of their reveal transaction.
+	for _, inscriptionID := range block.Inscriptions {
+		if traced[inscriptionID] {
+			continue
+		}
+		inscription, err := g.getInscription(inscriptionID)
+		if err != nil {
+			return nil, err
+		}
+		if !inscription.brc20 {
+			continue
+		}
+		t, found := b.positions[inscriptionID[:min(len(inscriptionID), 64)]]
+		if !found {
+			return nil, fmt.Errorf("the reveal transaction of %s isn't in block %d", inscriptionID, blockHeight)
+		}
+		landing, offset, err := b.land(t, 0)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, b.transfer(inscription, landing, offset, "", landing != t))
+	}
+
+	sort.SliceStable(transfers, func(i, j int) bool {
+		if transfers[i].tx != transfers[j].tx {
+			return transfers[i].tx < transfers[j].tx
+		}
+		return transfers[i].offset < transfers[j].offset
+	})
+	ordTransfers := make([]OrdTransfer, 0, len(transfers))
+	for i, transfer := range transfers {
+		transfer.ID = uint(i)
+		ordTransfers = append(ordTransfers, transfer.OrdTransfer)
+	}
+	return ordTransfers, nil
+}
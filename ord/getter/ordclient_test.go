@@ -0,0 +1,151 @@
+package getter
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord"
+)
+
+// ordServer stands in for `ord server` with the responses under testdata/ord, in the JSON shapes of ord's API:
+// the path /output/{txid}:{vout} is served from output_{txid}_{vout}.json, and the content raw from content_{id}.
+//
+// The block 780000 has the transactions in order:
+//
+//	0 the coinbase, taking the inscription V paid as fee by the transaction 5
+//	1 the reveal of the mint A, still on its output
+//	2 the reveal of the transfer T, moved by the transaction 3 in the same block
+//	3 the transfer of T
+//	4 the transfer of U inscribed in an earlier block
+//	5 the transfer of V inscribed in an earlier block, from its second input into the fees
+//	6 the reveal of the image I, which isn't brc-20
+//	7 the reveal of the mint G, moved out of the block since
+type ordServer struct {
+	mu      sync.Mutex
+	queries map[string]int
+}
+
+func (s *ordServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	s.mu.Lock()
+	s.queries[strings.Split(path, "/")[0]]++
+	s.mu.Unlock()
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(path)
+	if !strings.HasPrefix(path, "content/") {
+		name += ".json"
+	}
+	body, err := os.ReadFile(filepath.Join("testdata", "ord", name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+func TestOrdHTTPGetter(t *testing.T) {
+	const (
+		coinbaseTxID = "b1c38315a875f17eaf1bfab16797e517d8a1760b1db1f2ae1f3f9ae61f63c45b"
+		mintTxID     = "918965a7bdf4fb521fbf89ef44ccff23d8da84641347e5fc2b8bf4eab90a0343"
+		transferTxID = "96b462b9081a3b390106250e34b1ff271ebfd0ba5e127fd0baed4577e5f75fb9"
+		moveTxID     = "be11857a1395f1ee53cab632b26e4308942571456e2f1554af2b52254dffc6a6"
+		otherTxID    = "2034e6ce2604bed1d7a6a2ed11257535a6832f4a6b3440040e77e608cc02ceae"
+		movedTxID    = "9ec0ead3d01766b3810e5ed76862f4d22fe76afb83b59f4cca691e6eadec04e7"
+
+		mintA     = mintTxID + "i0"
+		transferT = transferTxID + "i0"
+		transferU = "b19de34633ea5dc4e955a9ce59b4c7f30f37cf13ca2b64aaeee29cca66d25ea2i0"
+		transferV = "da9f938a5e748f027caec1a6d0374cae5d5a36021794353ac2a088eb6402bc4ai0"
+		mintG     = movedTxID + "i0"
+
+		transferContent = `{"p":"brc-20","op":"transfer","tick":"ordi","amt":"5"}`
+		textPlain       = "text/plain;charset=utf-8"
+	)
+	script := func(s string) (ord.Pkscript, ord.Wallet) {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ord.Pkscript(s), wallet(b)
+	}
+	transfer := func(id uint, inscriptionID, oldSatpoint, newSatpoint, pkscript string, sentAsFee bool, content, contentType, parentID string) OrdTransfer {
+		newPkscript, newWallet := script(pkscript)
+		return OrdTransfer{ID: id, InscriptionID: inscriptionID, BlockHeight: 780000, OldSatpoint: oldSatpoint, NewSatpoint: newSatpoint,
+			NewPkscript: newPkscript, NewWallet: newWallet, SentAsFee: sentAsFee, Content: []byte(content), ContentType: contentType, ParentID: parentID}
+	}
+	expected := []OrdTransfer{
+		transfer(0, mintA, "", mintTxID+":0:0", "512095256875151043abdcafdd26fd390c650d6311e1d7185df477ce50736b6a5d0b", false,
+			`{"p":"brc-20","op":"mint","tick":"ordi","amt":"1000"}`, textPlain, ""),
+		transfer(1, transferT, "", transferTxID+":0:0", "51203c31ba8d4dab9e1f45dc499d788d1db449c91610c11b91122b194cd7e905fe2a", false,
+			transferContent, textPlain, mintA),
+		transfer(2, transferT, transferTxID+":0:0", moveTxID+":0:0", "0014447255344902a943e24efef86e8c6266c8c12c76", false,
+			transferContent, textPlain, mintA),
+		transfer(3, transferU, "0226a0113d223055cbfd648ec720fc5e66b2e66a61f9ea51ef0955a7a3295fe8:0:0", otherTxID+":0:0",
+			"0014cbf42478bb72ef2f2447180d85bf5a07a815d416", false, transferContent, textPlain, ""),
+		// G is placed by the sat flow of its reveal transaction.
+		transfer(4, mintG, "", movedTxID+":0:0", "5120212691287aaa56ba076676728529c2bb80ae9ad83339e67054c6854a38b351e8", false,
+			`{ "p": "brc-20", "op": "mint", "tick": "sats", "amt": "100000000" }`, textPlain, ""),
+		// The subsidy of 6.25 BTC and the fees of the transactions 1 to 4 come before V in the coinbase.
+		transfer(5, transferV, "145ff1697e56f59639e6619e9f02aa9926475fed2f1642bcedaf5c8181a91aba:0:0", coinbaseTxID+":0:625024508",
+			"00148b133a3868993176b613738816247a7f4d357cae", true, transferContent, "application/json", ""),
+	}
+
+	server := &ordServer{queries: make(map[string]int)}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	g, err := NewOrdHTTPGetter(ts.URL+"/", ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip, err := g.GetLatestBlockHeight(); err != nil || tip != 780003 {
+		t.Fatal("unexpected tip", tip, err)
+	}
+	if hash, err := g.GetBlockHash(780000); err != nil || hash != "0000000000000000083530a13e28899bb2e3083f68504f2122601fd95c66d37d" {
+		t.Fatal("unexpected block hash", hash, err)
+	}
+	if _, err := g.GetBlockHash(780001); !errors.Is(err, ErrBlockNotFound) {
+		t.Fatal("unexpected error of the missing block", err)
+	}
+
+	ordTransfers, err := g.GetOrdTransfers(780000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ordTransfers, expected) {
+		t.Fatalf("unexpected transfers:\n%+v\n%+v", ordTransfers, expected)
+	}
+
+	// The rows of ord_transfers joined by OPI for the block, the content is jsonb and the content type is hex encoded.
+	rows := make([]OrdTransfer, len(expected))
+	copy(rows, expected)
+	for i := range rows {
+		rows[i].ID += 1000
+		rows[i].ContentType = hex.EncodeToString([]byte(rows[i].ContentType))
+	}
+	rows[0].Content = []byte(`{"p": "brc-20", "op": "mint", "amt": "1000", "tick": "ordi"}`)
+	if DigestOrdTransfers(ordTransfers) != DigestOrdTransfers(rows) {
+		t.Fatal("mismatched digests of ord and OPI")
+	}
+
+	// The metadata and the content of the inscriptions are cached, their satpoints aren't.
+	blocks, err := g.GetOrdTransfersInRange(780000, 780000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 || !reflect.DeepEqual(blocks[0], expected) {
+		t.Fatal("unexpected transfers in range", blocks)
+	}
+	if server.queries["inscription"] != 6+4+4 || server.queries["content"] != 6 || server.queries["block"] != 2 {
+		t.Fatal("unexpected queries", server.queries)
+	}
+	if _, err := g.GetOrdTransfersInRange(780000, 780001); !errors.Is(err, ErrBlockNotFound) {
+		t.Fatal("unexpected error of the missing block", err)
+	}
+}
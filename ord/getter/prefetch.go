@@ -0,0 +1,116 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+)
+
+// groupByBlock splits the transfers sorted by block height into the blocks from fromHeight to toHeight.
+// It fails if a transfer is out of the range or out of order, e.g. the source returned rows it wasn't asked for.
+func groupByBlock(ordTransfers []OrdTransfer, fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	blocks := make([][]OrdTransfer, toHeight-fromHeight+1)
+	start := 0
+	for i := 1; i <= len(ordTransfers); i++ {
+		if i < len(ordTransfers) && ordTransfers[i].BlockHeight == ordTransfers[start].BlockHeight {
+			continue
+		}
+		height := ordTransfers[start].BlockHeight
+		if height < fromHeight || height > toHeight {
+			return nil, fmt.Errorf("the transfer %s at height %d is out of the block range %d to %d",
+				ordTransfers[start].InscriptionID, height, fromHeight, toHeight)
+		}
+		if blocks[height-fromHeight] != nil {
+			return nil, fmt.Errorf("the transfers at height %d are not sorted by the block height", height)
+		}
+		blocks[height-fromHeight] = ordTransfers[start:i:i]
+		start = i
+	}
+	return blocks, nil
+}
+
+type viewBatch struct {
+	fromHeight uint
+	view       *BlockView
+	err        error
+}
+
+// Prefetcher fetches the views of consecutive blocks in batches ahead of the consumer, see ReadBlockView.
+// Up to depth batches are fetched concurrently while the consumer executes the current block,
+// the blocks are still returned in order.
+type Prefetcher struct {
+	// The batches in the order of the heights, each one is delivered once its fetching is done.
+	batches chan chan viewBatch
+	cancel  context.CancelFunc
+
+	current    *BlockView
+	nextHeight uint
+	toHeight   uint
+}
+
+// NewPrefetcher starts to fetch the blocks from fromHeight to toHeight, batchSize blocks per query.
+// The fetching stops when ctx is done or Close is called.
+func NewPrefetcher(ctx context.Context, getter OrdGetter, fromHeight uint, toHeight uint, batchSize uint, depth int) *Prefetcher {
+	if batchSize == 0 {
+		batchSize = 1
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Prefetcher{
+		batches:    make(chan chan viewBatch, depth-1),
+		cancel:     cancel,
+		nextHeight: fromHeight,
+		toHeight:   toHeight,
+	}
+	go func() {
+		defer close(p.batches)
+		for from := fromHeight; from <= toHeight; from += batchSize {
+			to := min(from+batchSize-1, toHeight)
+			result := make(chan viewBatch, 1)
+			// Blocks while depth batches are waiting for the consumer.
+			select {
+			case p.batches <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func(from, to uint) {
+				view, err := ReadBlockView(getter, from, to)
+				result <- viewBatch{fromHeight: from, view: view, err: err}
+			}(from, to)
+			if to == toHeight {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Next returns the transfers and the hash of the next block.
+func (p *Prefetcher) Next() ([]OrdTransfer, string, error) {
+	if p.nextHeight > p.toHeight {
+		return nil, "", fmt.Errorf("the blocks up to height %d are all fetched", p.toHeight)
+	}
+	if p.current == nil || p.nextHeight > p.current.ToHeight {
+		result, ok := <-p.batches
+		if !ok {
+			return nil, "", fmt.Errorf("the fetching stopped before height %d", p.nextHeight)
+		}
+		batch := <-result
+		if batch.err != nil {
+			return nil, "", fmt.Errorf("failed to fetch the blocks from height %d: %w", batch.fromHeight, batch.err)
+		}
+		if batch.view.FromHeight != p.nextHeight {
+			return nil, "", fmt.Errorf("unexpected batch of blocks from height %d, expected height %d", batch.view.FromHeight, p.nextHeight)
+		}
+		p.current = batch.view
+	}
+	height := p.nextHeight
+	p.nextHeight++
+	return p.current.OrdTransfers(height), p.current.BlockHash(height), nil
+}
+
+// Close stops the fetching, the batches in flight are dropped.
+func (p *Prefetcher) Close() {
+	p.cancel()
+}
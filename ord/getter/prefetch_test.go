@@ -0,0 +1,131 @@
+package getter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rangeGetter serves two transfers on every third block, the range queries fail from failHeight.
+type rangeGetter struct {
+	failHeight uint
+	inFlight   atomic.Int32
+	maxFlight  atomic.Int32
+}
+
+func (g *rangeGetter) GetLatestBlockHeight() (uint, error) {
+	return 0, nil
+}
+
+func (g *rangeGetter) GetBlockHash(blockHeight uint) (string, error) {
+	return fmt.Sprintf("hash%d", blockHeight), nil
+}
+
+func (g *rangeGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	if blockHeight%3 != 0 {
+		return nil, nil
+	}
+	return []OrdTransfer{{ID: blockHeight * 10, BlockHeight: blockHeight}, {ID: blockHeight*10 + 1, BlockHeight: blockHeight}}, nil
+}
+
+func (g *rangeGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	n := g.inFlight.Add(1)
+	defer g.inFlight.Add(-1)
+	for {
+		m := g.maxFlight.Load()
+		if n <= m || g.maxFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	if g.failHeight != 0 && toHeight >= g.failHeight {
+		return nil, errors.New("connection refused")
+	}
+	var ordTransfers []OrdTransfer
+	for i := fromHeight; i <= toHeight; i++ {
+		transfers, _ := g.GetOrdTransfers(i)
+		ordTransfers = append(ordTransfers, transfers...)
+	}
+	return groupByBlock(ordTransfers, fromHeight, toHeight)
+}
+
+func TestPrefetcher(t *testing.T) {
+	g := &rangeGetter{}
+	blocks := NewPrefetcher(context.Background(), g, 10, 100, 7, 3)
+	defer blocks.Close()
+	for i := uint(10); i <= 100; i++ {
+		transfers, hash, err := blocks.Next()
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if expected, _ := g.GetBlockHash(i); hash != expected {
+			t.Fatalf("unexpected hash of block %d: %s", i, hash)
+		}
+		expected, _ := g.GetOrdTransfers(i)
+		if len(transfers) != len(expected) {
+			t.Fatalf("unexpected transfers of block %d: %v", i, transfers)
+		}
+		for j := range transfers {
+			if transfers[j].ID != expected[j].ID {
+				t.Fatalf("unexpected transfers of block %d: %v", i, transfers)
+			}
+		}
+		// Leave time for the batches to be fetched ahead.
+		time.Sleep(100 * time.Microsecond)
+	}
+	if _, _, err := blocks.Next(); err == nil {
+		t.Fatal("fetched beyond the last block")
+	}
+	if g.maxFlight.Load() > 3 {
+		t.Fatal("too many batches in flight", g.maxFlight.Load())
+	}
+
+	g = &rangeGetter{failHeight: 30}
+	blocks = NewPrefetcher(context.Background(), g, 10, 100, 7, 3)
+	defer blocks.Close()
+	for i := uint(10); i < 24; i++ {
+		if _, _, err := blocks.Next(); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	if _, _, err := blocks.Next(); err == nil {
+		t.Fatal("the failed batch is skipped")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	blocks = NewPrefetcher(ctx, &rangeGetter{}, 10, 100, 7, 3)
+	cancel()
+	var err error
+	for range 100 {
+		if _, _, err = blocks.Next(); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		t.Fatal("the fetching doesn't stop with the context")
+	}
+}
+
+func TestGroupByBlock(t *testing.T) {
+	transfers := []OrdTransfer{{ID: 1, BlockHeight: 10}, {ID: 2, BlockHeight: 10}, {ID: 3, BlockHeight: 12}}
+	blocks, err := groupByBlock(transfers, 10, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 4 || len(blocks[0]) != 2 || blocks[1] != nil || len(blocks[2]) != 1 || blocks[3] != nil {
+		t.Fatal("unexpected blocks", blocks)
+	}
+
+	for _, invalid := range [][]OrdTransfer{
+		{{ID: 1, BlockHeight: 9}},
+		{{ID: 1, BlockHeight: 10}, {ID: 2, BlockHeight: 14}},
+		{{ID: 1, BlockHeight: 10}, {ID: 2, BlockHeight: 11}, {ID: 3, BlockHeight: 10}},
+	} {
+		if _, err := groupByBlock(invalid, 10, 13); err == nil {
+			t.Fatal("the invalid transfers are grouped", invalid)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package getter
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// The headers of the fixtures read by OPIOrdGetterTest and FileOrdGetter.
+var (
+	blockHashesHeader  = []string{"block_height", "block_hash"}
+	ordTransfersHeader = []string{"id", "inscription_id", "block_height", "old_satpoint", "new_satpoint", "new_pkscript", "new_wallet", "sent_as_fee", "content", "content_type", "parent_id"}
+)
+
+// RecordingGetter decorates a getter and records its block hashes and transfers as the CSV fixtures
+// read by OPIOrdGetterTest. Each block is recorded once and written on Flush sorted by block height, then
+// by transfer ID, whatever the order the blocks are queried in. The failed queries and the latest block
+// height aren't recorded.
+type RecordingGetter struct {
+	getter OrdGetter
+
+	mu           sync.Mutex
+	blockHashes  *csv.Writer
+	ordTransfers *csv.Writer
+	hashed       map[uint]bool
+	transferred  map[uint]bool
+	// The blocks recorded since the last Flush.
+	hashes    map[uint]string
+	transfers map[uint][]OrdTransfer
+}
+
+func NewRecordingGetter(getter OrdGetter, blockHashes io.Writer, ordTransfers io.Writer) (*RecordingGetter, error) {
+	recorder := RecordingGetter{
+		getter:       getter,
+		blockHashes:  csv.NewWriter(blockHashes),
+		ordTransfers: csv.NewWriter(ordTransfers),
+		hashed:       make(map[uint]bool),
+		transferred:  make(map[uint]bool),
+		hashes:       make(map[uint]string),
+		transfers:    make(map[uint][]OrdTransfer),
+	}
+	err := recorder.blockHashes.Write(blockHashesHeader)
+	if err != nil {
+		return nil, err
+	}
+	err = recorder.ordTransfers.Write(ordTransfersHeader)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder, nil
+}
+
+func (r *RecordingGetter) recordBlockHash(blockHeight uint, blockHash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.hashed[blockHeight] {
+		r.hashed[blockHeight] = true
+		r.hashes[blockHeight] = blockHash
+	}
+}
+
+func (r *RecordingGetter) recordOrdTransfers(blockHeight uint, ordTransfers []OrdTransfer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.transferred[blockHeight] {
+		r.transferred[blockHeight] = true
+		r.transfers[blockHeight] = append([]OrdTransfer{}, ordTransfers...)
+	}
+}
+
+// sortedHeights returns the heights of the recorded blocks in ascending order.
+func sortedHeights[T any](blocks map[uint]T) []uint {
+	heights := make([]uint, 0, len(blocks))
+	for height := range blocks {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights
+}
+
+// Flush writes the recorded blocks to the writers, sorted by block height and then by transfer ID.
+// The blocks recorded after are written by the next Flush.
+func (r *RecordingGetter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, height := range sortedHeights(r.hashes) {
+		err := r.blockHashes.Write([]string{strconv.FormatUint(uint64(height), 10), r.hashes[height]})
+		if err != nil {
+			return err
+		}
+	}
+	r.hashes = make(map[uint]string)
+	r.blockHashes.Flush()
+	err := r.blockHashes.Error()
+	if err != nil {
+		return err
+	}
+
+	for _, height := range sortedHeights(r.transfers) {
+		ordTransfers := r.transfers[height]
+		sort.SliceStable(ordTransfers, func(i, j int) bool {
+			return ordTransfers[i].ID < ordTransfers[j].ID
+		})
+		for _, ot := range ordTransfers {
+			err := r.ordTransfers.Write([]string{
+				strconv.FormatUint(uint64(ot.ID), 10),
+				ot.InscriptionID,
+				strconv.FormatUint(uint64(ot.BlockHeight), 10),
+				ot.OldSatpoint,
+				ot.NewSatpoint,
+				string(ot.NewPkscript),
+				string(ot.NewWallet),
+				strconv.FormatBool(ot.SentAsFee),
+				string(ot.Content),
+				ot.ContentType,
+				ot.ParentID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	r.transfers = make(map[uint][]OrdTransfer)
+	r.ordTransfers.Flush()
+	return r.ordTransfers.Error()
+}
+
+func (r *RecordingGetter) GetLatestBlockHeight() (uint, error) {
+	return r.getter.GetLatestBlockHeight()
+}
+
+func (r *RecordingGetter) GetBlockHash(blockHeight uint) (string, error) {
+	blockHash, err := r.getter.GetBlockHash(blockHeight)
+	if err != nil {
+		return "", err
+	}
+	r.recordBlockHash(blockHeight, blockHash)
+	return blockHash, nil
+}
+
+func (r *RecordingGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	ordTransfers, err := r.getter.GetOrdTransfers(blockHeight)
+	if err != nil {
+		return nil, err
+	}
+	r.recordOrdTransfers(blockHeight, ordTransfers)
+	return ordTransfers, nil
+}
+
+func (r *RecordingGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	blocks, err := r.getter.GetOrdTransfersInRange(fromHeight, toHeight)
+	if err != nil {
+		return nil, err
+	}
+	for i, ordTransfers := range blocks {
+		r.recordOrdTransfers(fromHeight+uint(i), ordTransfers)
+	}
+	return blocks, nil
+}
@@ -0,0 +1,147 @@
+package getter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// fixtureGetter serves the transfers of rangeGetter with all the fields recorded in the fixtures.
+type fixtureGetter struct {
+	rangeGetter
+}
+
+func (g *fixtureGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	ordTransfers, _ := g.rangeGetter.GetOrdTransfers(blockHeight)
+	for i := range ordTransfers {
+		ot := &ordTransfers[i]
+		ot.InscriptionID = fmt.Sprintf("%064di0", ot.ID)
+		ot.NewSatpoint = fmt.Sprintf("%064d:0:0", ot.ID)
+		ot.NewPkscript = "5120aa"
+		ot.NewWallet = "bc1pa"
+		ot.SentAsFee = i == 1
+		ot.Content = []byte(`{"p": "brc-20", "op": "mint", "tick": "ordi", "amt": "1,000"}`)
+		ot.ContentType = "746578742f706c61696e"
+		if i == 1 {
+			ot.OldSatpoint = fmt.Sprintf("%064d:1:0", ot.ID)
+			ot.ParentID = ordTransfers[0].InscriptionID
+		}
+	}
+	return ordTransfers, nil
+}
+
+func (g *fixtureGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	blocks := make([][]OrdTransfer, 0, toHeight-fromHeight+1)
+	for i := fromHeight; i <= toHeight; i++ {
+		ordTransfers, _ := g.GetOrdTransfers(i)
+		blocks = append(blocks, ordTransfers)
+	}
+	return blocks, nil
+}
+
+func TestRecordingGetter(t *testing.T) {
+	dir := t.TempDir()
+	blockHashes, err := os.Create(filepath.Join(dir, "100-brc20_block_hashes.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockHashes.Close()
+	ordTransfers, err := os.Create(filepath.Join(dir, "100-ord_transfers.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ordTransfers.Close()
+
+	r, err := NewRecordingGetter(&fixtureGetter{}, blockHashes, ordTransfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := r.GetOrdTransfersInRange(90, 95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint(89); i <= 100; i++ {
+		if _, err := r.GetBlockHash(i); err != nil {
+			t.Fatal(err)
+		}
+		transfers, err := r.GetOrdTransfers(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 95 {
+			expected = append(expected, transfers)
+		}
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fixtures are read back by the file getter.
+	g, err := NewFileOrdGetter(blockHashes.Name(), ordTransfers.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if latest, _ := g.GetLatestBlockHeight(); latest != 100 {
+		t.Fatal("unexpected latest block height", latest)
+	}
+	if hash, err := g.GetBlockHash(89); err != nil || hash != "hash89" {
+		t.Fatal("unexpected block hash", hash, err)
+	}
+	blocks, err := g.GetOrdTransfersInRange(90, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range blocks {
+		if len(blocks[i]) != len(expected[i]) || (len(expected[i]) > 0 && !reflect.DeepEqual(blocks[i], expected[i])) {
+			t.Fatalf("unexpected transfers of block %d: %+v, expected %+v", 90+i, blocks[i], expected[i])
+		}
+	}
+}
+
+// shuffledGetter serves the transfers of fixtureGetter in the reverse order of their IDs.
+type shuffledGetter struct {
+	fixtureGetter
+}
+
+func (g *shuffledGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	ordTransfers, _ := g.fixtureGetter.GetOrdTransfers(blockHeight)
+	slices.Reverse(ordTransfers)
+	return ordTransfers, nil
+}
+
+func TestRecordingGetterOrder(t *testing.T) {
+	record := func(g OrdGetter, heights []uint) (string, string) {
+		var blockHashes, ordTransfers bytes.Buffer
+		r, err := NewRecordingGetter(g, &blockHashes, &ordTransfers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range heights {
+			if _, err := r.GetBlockHash(i); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.GetOrdTransfers(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		return blockHashes.String(), ordTransfers.String()
+	}
+
+	// The blocks complete out of order, as with the prefetcher.
+	expectedHashes, expectedTransfers := record(&fixtureGetter{}, []uint{90, 91, 92, 93, 94, 95})
+	blockHashes, ordTransfers := record(&shuffledGetter{}, []uint{93, 90, 95, 91, 94, 92, 90})
+	if blockHashes != expectedHashes {
+		t.Fatalf("unexpected block hashes:\n%s\nexpected:\n%s", blockHashes, expectedHashes)
+	}
+	if ordTransfers != expectedTransfers {
+		t.Fatalf("unexpected transfers:\n%s\nexpected:\n%s", ordTransfers, expectedTransfers)
+	}
+}
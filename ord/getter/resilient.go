@@ -0,0 +1,236 @@
+package getter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RiemaLabs/modular-indexer-committee/internal/metrics"
+)
+
+// ResilienceConfig tunes the ResilientGetter, the zero values fall back to the defaults.
+type ResilienceConfig struct {
+	// The timeout of each attempt, it applies to the getters implementing ContextGetter.
+	QueryTimeout time.Duration
+	// The number of attempts of a query, including the first one.
+	MaxAttempts int
+	// The backoff before the first retry, it doubles after each retry up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// The circuit opens after FailureThreshold consecutive failed queries and stays open for OpenDuration.
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+func (c *ResilienceConfig) withDefaults() ResilienceConfig {
+	config := *c
+	if config.QueryTimeout <= 0 {
+		config.QueryTimeout = 30 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = 500 * time.Millisecond
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = max(30*time.Second, config.MinBackoff)
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 3
+	}
+	if config.OpenDuration <= 0 {
+		config.OpenDuration = 30 * time.Second
+	}
+	return config
+}
+
+// ResilientGetter decorates a getter with timeouts, retries with exponential backoff and a circuit breaker.
+// ErrBlockNotFound and ErrBehindTip are returned at once since retrying doesn't help,
+// the blocks above the latest known height are rejected with ErrBehindTip without querying them.
+type ResilientGetter struct {
+	// The retries stop when ctx is done.
+	ctx    context.Context
+	getter OrdGetter
+	config ResilienceConfig
+
+	// The latest block height returned by the getter.
+	tip atomic.Uint64
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func NewResilientGetter(ctx context.Context, getter OrdGetter, config ResilienceConfig) *ResilientGetter {
+	return &ResilientGetter{
+		ctx:    ctx,
+		getter: getter,
+		config: config.withDefaults(),
+	}
+}
+
+// isPermanent reports whether the error is an answer of the getter rather than a failure of it.
+func isPermanent(err error) bool {
+	return errors.Is(err, ErrBlockNotFound) || errors.Is(err, ErrBehindTip) || errors.Is(err, context.Canceled)
+}
+
+func (g *ResilientGetter) allow() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Now().Before(g.openUntil) {
+		return fmt.Errorf("%w until %s", ErrCircuitOpen, g.openUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// record counts the consecutive failed queries, the circuit is half-open once OpenDuration passed,
+// so the next failure opens it again and the next success closes it.
+func (g *ResilientGetter) record(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err == nil || isPermanent(err) {
+		g.failures = 0
+		return
+	}
+	g.failures++
+	if g.failures >= g.config.FailureThreshold {
+		g.openUntil = time.Now().Add(g.config.OpenDuration)
+		log.Printf("Open the circuit of the getter for %v after %d failed queries: %v", g.config.OpenDuration, g.failures, err)
+	}
+}
+
+func (g *ResilientGetter) attempt(query func(OrdGetter) error) error {
+	ctx, cancel := context.WithTimeout(g.ctx, g.config.QueryTimeout)
+	defer cancel()
+	getter := g.getter
+	if contextGetter, ok := getter.(ContextGetter); ok {
+		getter = contextGetter.WithContext(ctx)
+	}
+	return query(getter)
+}
+
+// do runs the query with retries, op names the query in the logs and the metrics.
+func (g *ResilientGetter) do(op string, query func(OrdGetter) error) error {
+	err := g.allow()
+	if err != nil {
+		return err
+	}
+	backoff := g.config.MinBackoff
+	for attempt := 1; ; attempt++ {
+		err = g.attempt(query)
+		if err == nil || isPermanent(err) || attempt >= g.config.MaxAttempts {
+			break
+		}
+		log.Printf("Failed to %s, retry in %v: %v", op, backoff, err)
+		metrics.DBQueryRetries.WithLabelValues(op).Inc()
+		select {
+		case <-g.ctx.Done():
+			return errors.Join(err, g.ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, g.config.MaxBackoff)
+	}
+	g.record(err)
+	return err
+}
+
+// checkTip rejects the height above the latest block, the latest block height is refreshed once before that.
+func (g *ResilientGetter) checkTip(blockHeight uint) error {
+	if uint64(blockHeight) <= g.tip.Load() {
+		return nil
+	}
+	tip, err := g.GetLatestBlockHeight()
+	if err != nil {
+		return err
+	}
+	if blockHeight > tip {
+		return fmt.Errorf("%w: height %d, tip %d", ErrBehindTip, blockHeight, tip)
+	}
+	return nil
+}
+
+func (g *ResilientGetter) GetLatestBlockHeight() (uint, error) {
+	var blockHeight uint
+	err := g.do("getLatestBlockHeight", func(getter OrdGetter) error {
+		var err error
+		blockHeight, err = getter.GetLatestBlockHeight()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	// The tip may go down while OPI handles a reorg.
+	g.tip.Store(uint64(blockHeight))
+	return blockHeight, nil
+}
+
+func (g *ResilientGetter) GetBlockHash(blockHeight uint) (string, error) {
+	err := g.checkTip(blockHeight)
+	if err != nil {
+		return "", err
+	}
+	var blockHash string
+	err = g.do("getBlockHash", func(getter OrdGetter) error {
+		var err error
+		blockHash, err = getter.GetBlockHash(blockHeight)
+		return err
+	})
+	return blockHash, err
+}
+
+func (g *ResilientGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	err := g.checkTip(blockHeight)
+	if err != nil {
+		return nil, err
+	}
+	var ordTransfers []OrdTransfer
+	err = g.do("getOrdTransfers", func(getter OrdGetter) error {
+		var err error
+		ordTransfers, err = getter.GetOrdTransfers(blockHeight)
+		return err
+	})
+	return ordTransfers, err
+}
+
+func (g *ResilientGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	err := g.checkTip(toHeight)
+	if err != nil {
+		return nil, err
+	}
+	var blocks [][]OrdTransfer
+	err = g.do("getOrdTransfersInRange", func(getter OrdGetter) error {
+		var err error
+		blocks, err = getter.GetOrdTransfersInRange(fromHeight, toHeight)
+		return err
+	})
+	return blocks, err
+}
+
+// GetBlockView reads the view with retries, it is consistent if the decorated getter implements BlockViewer.
+func (g *ResilientGetter) GetBlockView(fromHeight uint, toHeight uint) (*BlockView, error) {
+	err := g.checkTip(toHeight)
+	if err != nil {
+		return nil, err
+	}
+	var view *BlockView
+	err = g.do("getBlockView", func(getter OrdGetter) error {
+		var err error
+		view, err = ReadBlockView(getter, fromHeight, toHeight)
+		return err
+	})
+	return view, err
+}
+
+// SubscribeNewBlocks forwards the subscription to the decorated getter.
+func (g *ResilientGetter) SubscribeNewBlocks(ctx context.Context, channel string) (<-chan struct{}, error) {
+	notifier, ok := g.getter.(BlockNotifier)
+	if !ok {
+		return nil, errors.New("the getter doesn't support the notification of new blocks")
+	}
+	return notifier.SubscribeNewBlocks(ctx, channel)
+}
@@ -0,0 +1,103 @@
+package getter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// flakyGetter fails the next failures queries, the blocks up to tip exist.
+type flakyGetter struct {
+	tip      uint
+	failures int
+	queries  int
+}
+
+func (g *flakyGetter) query() error {
+	g.queries++
+	if g.failures > 0 {
+		g.failures--
+		return errors.New("connection reset by peer")
+	}
+	return nil
+}
+
+func (g *flakyGetter) GetLatestBlockHeight() (uint, error) {
+	return g.tip, g.query()
+}
+
+func (g *flakyGetter) GetBlockHash(blockHeight uint) (string, error) {
+	if err := g.query(); err != nil {
+		return "", err
+	}
+	if blockHeight > g.tip {
+		return "", fmt.Errorf("%w: height %d", ErrBlockNotFound, blockHeight)
+	}
+	return fmt.Sprintf("hash%d", blockHeight), nil
+}
+
+func (g *flakyGetter) GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error) {
+	return nil, g.query()
+}
+
+func (g *flakyGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error) {
+	return make([][]OrdTransfer, toHeight-fromHeight+1), g.query()
+}
+
+func TestResilientGetter(t *testing.T) {
+	inner := &flakyGetter{tip: 10}
+	g := NewResilientGetter(context.Background(), inner, ResilienceConfig{
+		MaxAttempts:      3,
+		MinBackoff:       time.Millisecond,
+		MaxBackoff:       2 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenDuration:     50 * time.Millisecond,
+	})
+
+	inner.failures = 2
+	if hash, err := g.GetBlockHash(5); err != nil || hash != "hash5" {
+		t.Fatal("the transient failures are not retried", hash, err)
+	}
+
+	// The tip is refreshed once, then the block is rejected without querying it.
+	inner.queries = 0
+	if _, err := g.GetOrdTransfers(11); !errors.Is(err, ErrBehindTip) || inner.queries != 1 {
+		t.Fatal("unexpected error above the tip", err, inner.queries)
+	}
+	inner.tip = 12
+	if _, err := g.GetOrdTransfersInRange(5, 12); err != nil {
+		t.Fatal(err)
+	}
+
+	// The getter answers that the block doesn't exist, it is not retried.
+	inner.tip = 8
+	inner.queries = 0
+	if _, err := g.GetBlockHash(9); !errors.Is(err, ErrBlockNotFound) || inner.queries != 1 {
+		t.Fatal("unexpected error of the missing block", err, inner.queries)
+	}
+
+	inner.failures = 6
+	for range 2 {
+		if _, err := g.GetLatestBlockHeight(); err == nil {
+			t.Fatal("the persistent failures are hidden")
+		}
+	}
+	inner.queries = 0
+	if _, err := g.GetLatestBlockHeight(); !errors.Is(err, ErrCircuitOpen) || inner.queries != 0 {
+		t.Fatal("the circuit isn't open", err, inner.queries)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if tip, err := g.GetLatestBlockHeight(); err != nil || tip != 8 {
+		t.Fatal("the circuit isn't closed", tip, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g = NewResilientGetter(ctx, inner, ResilienceConfig{MinBackoff: time.Hour})
+	inner.failures = 1
+	cancel()
+	if _, err := g.GetLatestBlockHeight(); !errors.Is(err, context.Canceled) {
+		t.Fatal("the retries don't stop with the context", err)
+	}
+}
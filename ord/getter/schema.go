@@ -0,0 +1,155 @@
+package getter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RiemaLabs/modular-indexer-committee/internal/metrics"
+)
+
+// The release of OPI the queries are written against, only its major and minor versions are compared.
+const SupportedOPIVersion = "0.4"
+
+// The columns read by OPIOrdGetter with their data types, an empty type accepts any type.
+var requiredColumns = map[string]map[string]string{
+	"block_hashes": {
+		"block_height": "",
+		"block_hash":   "",
+	},
+	"ord_transfers": {
+		"id":             "",
+		"inscription_id": "",
+		"block_height":   "",
+		"old_satpoint":   "",
+		"new_satpoint":   "",
+		"new_pkscript":   "",
+		"new_wallet":     "",
+		"sent_as_fee":    "boolean",
+	},
+	"ord_content": {
+		"inscription_id": "",
+		"content":        "jsonb",
+		"content_type":   "",
+	},
+	"ord_number_to_id": {
+		"inscription_id":   "",
+		"cursed_for_brc20": "boolean",
+		"parent_id":        "",
+	},
+	"ord_indexer_version": {
+		"indexer_version": "",
+		"db_version":      "",
+	},
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// SchemaReport describes the compatibility of the OPI database with the queries of OPIOrdGetter.
+type SchemaReport struct {
+	// The version recorded by the OPI main indexer, empty if it isn't recorded.
+	IndexerVersion string
+	DBVersion      int
+	// The incompatibilities, e.g. the missing tables and columns.
+	Problems []string
+}
+
+func (r *SchemaReport) Compatible() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *SchemaReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "OPI indexer version: %q, db version: %d, supported version: %s", r.IndexerVersion, r.DBVersion, SupportedOPIVersion)
+	for _, problem := range r.Problems {
+		fmt.Fprintf(&b, "\n  - %s", problem)
+	}
+	return b.String()
+}
+
+// compareSchema checks the columns, keyed by table and column name with their data types, and the recorded version.
+func compareSchema(columns map[string]map[string]string, indexerVersion string, dbVersion int) *SchemaReport {
+	report := SchemaReport{
+		IndexerVersion: indexerVersion,
+		DBVersion:      dbVersion,
+	}
+	for table, required := range requiredColumns {
+		existing, found := columns[table]
+		if !found {
+			report.Problems = append(report.Problems, fmt.Sprintf("missing table %s", table))
+			continue
+		}
+		for column, dataType := range required {
+			actual, found := existing[column]
+			if !found {
+				report.Problems = append(report.Problems, fmt.Sprintf("missing column %s.%s", table, column))
+			} else if dataType != "" && actual != dataType {
+				report.Problems = append(report.Problems, fmt.Sprintf("column %s.%s is %s, expected %s", table, column, actual, dataType))
+			}
+		}
+	}
+	if _, found := columns["ord_indexer_version"]; found {
+		match := versionPattern.FindString(indexerVersion)
+		if match == "" {
+			report.Problems = append(report.Problems, "the version of OPI isn't recorded in ord_indexer_version")
+		} else if match != SupportedOPIVersion {
+			report.Problems = append(report.Problems, fmt.Sprintf("unsupported OPI version %s, expected %s", match, SupportedOPIVersion))
+		}
+	}
+	sort.Strings(report.Problems)
+	return &report
+}
+
+// CheckSchema probes the tables, the columns and the version of the OPI database, the detected version is exported as a metric.
+// The error is only returned if the probe itself fails, the incompatibilities are listed in the report.
+func (opi *OPIOrdGetter) CheckSchema() (*SchemaReport, error) {
+	defer metrics.ObserveDBQuery("checkSchema", time.Now())
+
+	tables := make([]string, 0, len(requiredColumns))
+	for table := range requiredColumns {
+		tables = append(tables, table)
+	}
+	var rows []struct {
+		TableName  string
+		ColumnName string
+		DataType   string
+	}
+	sql := `
+		SELECT table_name, column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name IN ?
+	`
+	err := opi.db.Raw(sql, tables).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]map[string]string)
+	for _, row := range rows {
+		if columns[row.TableName] == nil {
+			columns[row.TableName] = make(map[string]string)
+		}
+		columns[row.TableName][row.ColumnName] = row.DataType
+	}
+
+	var version struct {
+		IndexerVersion string
+		DbVersion      int
+	}
+	if _, found := columns["ord_indexer_version"]; found {
+		sql := `
+			SELECT indexer_version, db_version
+			FROM ord_indexer_version LIMIT 1
+		`
+		err := opi.db.Raw(sql).Scan(&version).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	report := compareSchema(columns, version.IndexerVersion, version.DbVersion)
+	if report.IndexerVersion != "" {
+		metrics.OPIVersion.WithLabelValues(report.IndexerVersion).Set(float64(report.DBVersion))
+	}
+	return report, nil
+}
@@ -0,0 +1,46 @@
+package getter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareSchema(t *testing.T) {
+	columns := func() map[string]map[string]string {
+		columns := make(map[string]map[string]string)
+		for table, required := range requiredColumns {
+			columns[table] = make(map[string]string)
+			for column, dataType := range required {
+				if dataType == "" {
+					dataType = "text"
+				}
+				columns[table][column] = dataType
+			}
+		}
+		return columns
+	}
+
+	report := compareSchema(columns(), "opi-ord-indexer v0.4.1", 5)
+	if !report.Compatible() {
+		t.Fatal("the schema of the supported OPI is rejected", report)
+	}
+
+	c := columns()
+	delete(c["ord_number_to_id"], "parent_id")
+	c["ord_number_to_id"]["cursed_for_brc20"] = "integer"
+	delete(c, "ord_content")
+	report = compareSchema(c, "v0.3.0", 3)
+	expected := []string{
+		"column ord_number_to_id.cursed_for_brc20 is integer, expected boolean",
+		"missing column ord_number_to_id.parent_id",
+		"missing table ord_content",
+		"unsupported OPI version 0.3, expected 0.4",
+	}
+	if report.Compatible() || strings.Join(report.Problems, "\n") != strings.Join(expected, "\n") {
+		t.Fatal("unexpected report", report)
+	}
+
+	if report := compareSchema(columns(), "", 0); report.Compatible() {
+		t.Fatal("the unrecorded version is accepted")
+	}
+}
@@ -1,6 +1,10 @@
 package getter
 
-import "github.com/RiemaLabs/modular-indexer-committee/ord"
+import (
+	"context"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord"
+)
 
 // TODO: High. Record Old satpoint- Current satpoint to get OrdTransfer from the Bitcoin block directly.
 type OrdTransfer struct {
@@ -21,4 +25,19 @@ type OrdGetter interface {
 	GetLatestBlockHeight() (uint, error)
 	GetBlockHash(blockHeight uint) (string, error)
 	GetOrdTransfers(blockHeight uint) ([]OrdTransfer, error)
+	// GetOrdTransfersInRange returns the transfers of the blocks from fromHeight to toHeight inclusively,
+	// the i-th element holds the transfers of the block at fromHeight + i in the same order as GetOrdTransfers.
+	GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]OrdTransfer, error)
+}
+
+// BlockNotifier is implemented by the getters able to push the new blocks instead of being polled.
+type BlockNotifier interface {
+	// SubscribeNewBlocks returns a channel signaled when new blocks may be available, until ctx is done.
+	// The signals are coalesced, a single signal may stand for several blocks.
+	SubscribeNewBlocks(ctx context.Context, channel string) (<-chan struct{}, error)
+}
+
+// ContextGetter is implemented by the getters whose queries can be bound to a context, e.g. for a timeout.
+type ContextGetter interface {
+	WithContext(ctx context.Context) OrdGetter
 }
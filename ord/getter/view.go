@@ -0,0 +1,72 @@
+package getter
+
+import "fmt"
+
+// BlockView holds the hashes and the transfers of consecutive blocks.
+type BlockView struct {
+	FromHeight uint
+	ToHeight   uint
+	// Hashes[i] is the hash of the block at FromHeight - 1 + i, the parent of the first block is included
+	// so the view links to the state it is executed on.
+	Hashes []string
+	// Transfers[i] is the transfers of the block at FromHeight + i.
+	Transfers [][]OrdTransfer
+}
+
+// BlockHash returns the hash of the block at height, from FromHeight - 1 to ToHeight.
+func (v *BlockView) BlockHash(height uint) string {
+	return v.Hashes[height+1-v.FromHeight]
+}
+
+// OrdTransfers returns the transfers of the block at height, from FromHeight to ToHeight.
+func (v *BlockView) OrdTransfers(height uint) []OrdTransfer {
+	return v.Transfers[height-v.FromHeight]
+}
+
+func (v *BlockView) check() error {
+	if len(v.Hashes) != int(v.ToHeight-v.FromHeight+2) || len(v.Transfers) != int(v.ToHeight-v.FromHeight+1) {
+		return fmt.Errorf("incomplete view of the blocks from %d to %d: %d hashes and %d blocks",
+			v.FromHeight, v.ToHeight, len(v.Hashes), len(v.Transfers))
+	}
+	return nil
+}
+
+// BlockViewer is implemented by the getters able to read the blocks from a single consistent snapshot,
+// so the view never mixes two chains while OPI handles a reorg.
+type BlockViewer interface {
+	GetBlockView(fromHeight uint, toHeight uint) (*BlockView, error)
+}
+
+// ReadBlockView reads the blocks from fromHeight to toHeight with the hash of their parent.
+// The reads are consistent only if the getter implements BlockViewer, otherwise they are separate queries.
+func ReadBlockView(getter OrdGetter, fromHeight uint, toHeight uint) (*BlockView, error) {
+	if toHeight < fromHeight || fromHeight == 0 {
+		return nil, fmt.Errorf("invalid block range: %d to %d", fromHeight, toHeight)
+	}
+	if viewer, ok := getter.(BlockViewer); ok {
+		view, err := viewer.GetBlockView(fromHeight, toHeight)
+		if err != nil {
+			return nil, err
+		}
+		return view, view.check()
+	}
+	transfers, err := getter.GetOrdTransfersInRange(fromHeight, toHeight)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, toHeight-fromHeight+2)
+	for i := fromHeight - 1; i <= toHeight; i++ {
+		hash, err := getter.GetBlockHash(i)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	view := &BlockView{
+		FromHeight: fromHeight,
+		ToHeight:   toHeight,
+		Hashes:     hashes,
+		Transfers:  transfers,
+	}
+	return view, view.check()
+}
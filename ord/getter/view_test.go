@@ -0,0 +1,60 @@
+package getter
+
+import (
+	"fmt"
+	"testing"
+)
+
+// viewGetter reads the views in one piece, the hashes are those of rangeGetter.
+type viewGetter struct {
+	rangeGetter
+	views int
+	// Drop the hash of the last block, as if OPI had removed it.
+	incomplete bool
+}
+
+func (g *viewGetter) GetBlockView(fromHeight uint, toHeight uint) (*BlockView, error) {
+	g.views++
+	view := BlockView{FromHeight: fromHeight, ToHeight: toHeight}
+	for i := fromHeight - 1; i <= toHeight; i++ {
+		hash, _ := g.GetBlockHash(i)
+		view.Hashes = append(view.Hashes, hash)
+	}
+	if g.incomplete {
+		view.Hashes = view.Hashes[:len(view.Hashes)-1]
+	}
+	view.Transfers, _ = g.GetOrdTransfersInRange(fromHeight, toHeight)
+	return &view, nil
+}
+
+func TestReadBlockView(t *testing.T) {
+	viewer := &viewGetter{}
+	for _, g := range []OrdGetter{&rangeGetter{}, viewer} {
+		view, err := ReadBlockView(g, 5, 9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := uint(4); i <= 9; i++ {
+			if view.BlockHash(i) != fmt.Sprintf("hash%d", i) {
+				t.Fatal("unexpected hash", i, view.BlockHash(i))
+			}
+		}
+		for i := uint(5); i <= 9; i++ {
+			expected, _ := g.GetOrdTransfers(i)
+			if len(view.OrdTransfers(i)) != len(expected) {
+				t.Fatal("unexpected transfers", i, view.OrdTransfers(i))
+			}
+		}
+	}
+	if viewer.views != 1 {
+		t.Fatal("the view isn't read by the viewer")
+	}
+
+	viewer.incomplete = true
+	if _, err := ReadBlockView(viewer, 5, 9); err == nil {
+		t.Fatal("the incomplete view is accepted")
+	}
+	if _, err := ReadBlockView(viewer, 0, 9); err == nil {
+		t.Fatal("the view without the parent of the first block is accepted")
+	}
+}
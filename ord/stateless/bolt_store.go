@@ -0,0 +1,100 @@
+package stateless
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-verkle"
+	bolt "go.etcd.io/bbolt"
+)
+
+var stateBucket = []byte("state")
+
+// BoltStore keeps all key values in an embedded bolt database on the disk.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0666, &bolt.Options{
+		Timeout:      time.Second,
+		FreelistType: bolt.FreelistMapType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the state store at %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(stateBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Get(key [verkle.KeySize]byte) ([ValueSize]byte, bool, error) {
+	var value [ValueSize]byte
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(stateBucket).Get(key[:])
+		if v == nil {
+			return nil
+		}
+		if len(v) != ValueSize {
+			return fmt.Errorf("the length of the stored value must be %d, current is: %d", ValueSize, len(v))
+		}
+		copy(value[:], v)
+		found = true
+		return nil
+	})
+	return value, found, err
+}
+
+func (b *BoltStore) Apply(puts KeyValueMap, deletes [][verkle.KeySize]byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(stateBucket)
+		for key, value := range puts {
+			if err := bucket.Put(key[:], value[:]); err != nil {
+				return err
+			}
+		}
+		for _, key := range deletes {
+			if err := bucket.Delete(key[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) Iterate(fn func(key [verkle.KeySize]byte, value [ValueSize]byte) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).ForEach(func(k, v []byte) error {
+			if len(k) != verkle.KeySize || len(v) != ValueSize {
+				return fmt.Errorf("invalid key value in the state store, key length: %d, value length: %d", len(k), len(v))
+			}
+			return fn([verkle.KeySize]byte(k), [ValueSize]byte(v))
+		})
+	})
+}
+
+func (b *BoltStore) Clear() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(stateBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(stateBucket)
+		return err
+	})
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
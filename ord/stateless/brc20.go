@@ -64,6 +64,7 @@ var LimitPerMint LocationID = 0x03
 var Decimals LocationID = 0x04
 var IsSelfMint LocationID = 0x05
 var InscriptionID LocationID = 0x06 // inscription should take 2 slots, next should start with 08
+var BurnedSupply LocationID = 0x08
 
 func GetTickHash(tick string, locationID LocationID) []byte {
 	tickBytes := []byte(tick)
@@ -86,6 +87,12 @@ func updateTickState(f func(*uint256.Int) *uint256.Int, state KVStorage, tick st
 	state.InsertUInt256(key, res)
 }
 
+func GetBurnedSupply(state KVStorage, tick string) ([]byte, *uint256.Int) {
+	key := GetTickHash(tick, BurnedSupply)
+	value := state.GetUInt256(key)
+	return key, value
+}
+
 // Wallet State
 // Key: Keccak256(wallet + "GetWalletHash")[:StemSize] + LocationID
 // Value: []byte (Less than 1534 bytes)
@@ -253,10 +260,15 @@ func transferTransferNormal(state KVStorage, inscriptionID string, spentPkscript
 	f_add := func(v *uint256.Int) *uint256.Int {
 		return uint256.NewInt(0).Add(v, amount)
 	}
-	updateBalance(f_add, state, tick, spentPkscript, AvailableBalancePkscript)
-	updateBalance(f_add, state, tick, spentPkscript, OverallBalancePkscript)
 	updateLatestPkscript(state, sourceWallet, sourcePkscript)
-	updateLatestPkscript(state, spentWallet, spentPkscript)
+	if isBurnPkscript(spentPkscript) {
+		// The burn pkscript is never credited, the amount is added to the burned supply of the tick.
+		updateTickState(f_add, state, tick, BurnedSupply)
+	} else {
+		updateBalance(f_add, state, tick, spentPkscript, AvailableBalancePkscript)
+		updateBalance(f_add, state, tick, spentPkscript, OverallBalancePkscript)
+		updateLatestPkscript(state, spentWallet, spentPkscript)
+	}
 
 	// update transfer-transfer event count
 	key := GetEventHash(inscriptionID, TransferTransferCount)
@@ -264,7 +276,6 @@ func transferTransferNormal(state KVStorage, inscriptionID string, spentPkscript
 	state.InsertUInt256(key, newEventCount)
 }
 
-// TODO: High. Include burn logic.
 // Input previous verkle tree and all ord records in a block, then get the K-V array that the verkle tree should update
 func Exec(state KVStorage, ots []getter.OrdTransfer, blockHeight uint) {
 	if state.GetHeight() != blockHeight-1 {
@@ -0,0 +1,61 @@
+package stateless
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord"
+	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
+)
+
+func TestBurnTransfer(t *testing.T) {
+	const (
+		pkscript ord.Pkscript = "0014751e76e8199196d454941c45d1b3a323f1433bd6"
+		wallet   ord.Wallet   = "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+		transfer              = "b61b0172d95e266c18aea0c624db987e971a5d6d4ebc2aaed85da4642d635735i0"
+	)
+	inscribe := func(content string, oldSatpoint string, newPkscript ord.Pkscript, newWallet ord.Wallet) getter.OrdTransfer {
+		return getter.OrdTransfer{
+			InscriptionID: transfer,
+			OldSatpoint:   oldSatpoint,
+			NewPkscript:   newPkscript,
+			NewWallet:     newWallet,
+			Content:       []byte(content),
+			ContentType:   "text/plain;charset=utf-8",
+		}
+	}
+	blocks := [][]getter.OrdTransfer{
+		{inscribe(`{"p":"brc-20","op":"deploy","tick":"ordi","max":"1000","lim":"1000"}`, "", pkscript, wallet)},
+		{inscribe(`{"p":"brc-20","op":"mint","tick":"ordi","amt":"100"}`, "", pkscript, wallet)},
+		{inscribe(`{"p":"brc-20","op":"transfer","tick":"ordi","amt":"40"}`, "", pkscript, wallet)},
+		// The transfer inscription is sent to an OP_RETURN output, which has no wallet.
+		{inscribe(`{"p":"brc-20","op":"transfer","tick":"ordi","amt":"40"}`, transfer+":0:0", BurnPkscript, "")},
+	}
+
+	header := newTestHeader()
+	for i, ots := range blocks {
+		Exec(header, ots, uint(i+1))
+		if err := header.PagingWithHash("hash", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decimals := uint256.NewInt(0).Exp(uint256.NewInt(10), uint256.NewInt(18))
+	amount := func(v uint64) *uint256.Int {
+		return uint256.NewInt(0).Mul(uint256.NewInt(v), decimals)
+	}
+	if _, burned := GetBurnedSupply(header, "ordi"); !burned.Eq(amount(40)) {
+		t.Fatal("unexpected burned supply", burned)
+	}
+	if _, _, available, overall := GetBalances(header, "ordi", pkscript); !available.Eq(amount(60)) || !overall.Eq(amount(60)) {
+		t.Fatal("unexpected balances of the sender", available, overall)
+	}
+	if _, _, available, overall := GetBalances(header, "ordi", BurnPkscript); !available.IsZero() || !overall.IsZero() {
+		t.Fatal("the burn pkscript is credited", available, overall)
+	}
+	// The burned amount stays minted.
+	if remaining := header.GetUInt256(GetTickHash("ordi", RemainingSupply)); !remaining.Eq(amount(900)) {
+		t.Fatal("unexpected remaining supply", remaining)
+	}
+}
@@ -0,0 +1,58 @@
+package stateless
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-verkle"
+)
+
+// KeyValueIterator walks key values, it is satisfied by StateStore.Iterate.
+type KeyValueIterator func(fn func(key [verkle.KeySize]byte, value [ValueSize]byte) error) error
+
+// BuildTree builds the verkle tree from the key values in bulk instead of inserting them one by one.
+// The key values are grouped and sorted by stem, the leaf nodes and their commitments are built in parallel,
+// the subtrees under the root are filled in parallel and the inner commitments are computed bottom-up by Commit.
+// The commitment is identical to the one of the tree built by Insert.
+func BuildTree(iterate KeyValueIterator, nodeResolverFn verkle.NodeResolverFn) (verkle.VerkleNode, error) {
+	stems := make(map[[verkle.StemSize]byte]map[byte][]byte)
+	err := iterate(func(key [verkle.KeySize]byte, value [ValueSize]byte) error {
+		stem := [verkle.StemSize]byte(key[:verkle.StemSize])
+		values, found := stems[stem]
+		if !found {
+			values = make(map[byte][]byte)
+			stems[stem] = values
+		}
+		values[key[verkle.StemSize]] = value[:]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]verkle.BatchNewLeafNodeData, 0, len(stems))
+	for stem, values := range stems {
+		nodes = append(nodes, verkle.BatchNewLeafNodeData{
+			Stem:   stem[:],
+			Values: values,
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].Stem, nodes[j].Stem) < 0
+	})
+
+	root := verkle.New().(*verkle.InternalNode)
+	if len(nodes) != 0 {
+		leaves, err := verkle.BatchNewLeafNode(nodes)
+		if err != nil {
+			return nil, err
+		}
+		err = root.InsertMigratedLeaves(leaves, nodeResolverFn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The call of Commit is necessary to refresh the root commit.
+	root.Commit()
+	return root, nil
+}
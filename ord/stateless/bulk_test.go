@@ -0,0 +1,68 @@
+package stateless
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+)
+
+func TestBuildTree(t *testing.T) {
+	store := NewMemoryStore()
+	kv := make(KeyValueMap)
+	r := rand.New(rand.NewSource(1))
+	for i := range 2000 {
+		var key [verkle.KeySize]byte
+		r.Read(key[:])
+		// Share the stems between some keys, and keep some values zero.
+		if i%3 == 0 {
+			key[0] = byte(i % 7)
+		}
+		var value [ValueSize]byte
+		if i%5 != 0 {
+			r.Read(value[:])
+		}
+		kv[key] = value
+	}
+	if err := store.Apply(kv, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := verkle.New()
+	for key, value := range kv {
+		if err := expected.Insert(key[:], value[:], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, err := BuildTree(store.Iterate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Commit().Bytes() != expected.Commit().Bytes() {
+		t.Fatal("mismatched commitment between the bulk built tree and the inserted tree")
+	}
+
+	// The built tree keeps working with the updates.
+	var key [verkle.KeySize]byte
+	for k := range kv {
+		key = k
+		break
+	}
+	value := [ValueSize]byte{1}
+	for _, tree := range []verkle.VerkleNode{root, expected} {
+		if err := tree.Insert(key[:], value[:], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if root.Commit().Bytes() != expected.Commit().Bytes() {
+		t.Fatal("mismatched commitment after the update")
+	}
+
+	empty, err := BuildTree(NewMemoryStore().Iterate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Commit().Bytes() != verkle.New().Commit().Bytes() {
+		t.Fatal("mismatched commitment of the empty tree")
+	}
+}
@@ -1,11 +1,13 @@
 package stateless
 
 import (
-	"bytes"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"sort"
+	"time"
 
 	"github.com/ethereum/go-verkle"
 	"github.com/holiman/uint256"
@@ -14,95 +16,78 @@ import (
 	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
 )
 
-func (h *Header) insert(key []byte, value []byte, nodeResolverFn verkle.NodeResolverFn) {
-	if len(key) != verkle.KeySize {
-		panic(fmt.Errorf("the length the key to insert bytes must be %d, current is: %d", verkle.KeySize, len(key)))
-	}
-	if len(value) != ValueSize {
-		panic(fmt.Errorf("the length the value must be %d, current is: %d", ValueSize, len(key)))
+// accessed returns the index of the key in the access list, the index map is built on the first access.
+func (h *Header) accessed(key [verkle.KeySize]byte) (int, bool) {
+	if h.accessIndex == nil {
+		h.accessIndex = make(map[[verkle.KeySize]byte]int, len(h.Access.Elements))
+		for i, ele := range h.Access.Elements {
+			h.accessIndex[ele.Key] = i
+		}
 	}
+	i, found := h.accessIndex[key]
+	return i, found
+}
+
+// resetAccess clears the access list along with its index, e.g. after the paging.
+func (h *Header) resetAccess() {
+	h.Access = AccessList{}
+	h.accessIndex = nil
+}
 
-	// Get the old value from the verkle tree root.
-	oldValue, err := h.Root.Get(key, nodeResolverFn)
+// access records the first access of the key with its value on the tree.
+// The recorded old value serves the later accesses of the key in the block without looking up the tree again.
+func (h *Header) access(key [verkle.KeySize]byte, nodeResolverFn verkle.NodeResolverFn) int {
+	if i, found := h.accessed(key); found {
+		return i
+	}
+	oldValue, err := h.Root.Get(key[:], nodeResolverFn)
 	if err != nil {
 		panic(err)
 	}
 	oldValueExists := len(oldValue) > 0
 
-	var keyArray [verkle.KeySize]byte
-	copy(keyArray[:], key)
-
 	var oldValueArray [ValueSize]byte
 	if oldValueExists {
 		copy(oldValueArray[:], oldValue)
+	} else {
+		oldValueArray = defaultValue()
 	}
-
-	var newValueArray [ValueSize]byte
-	copy(newValueArray[:], value)
-
-	// TODO: Medium. Optimize the access to Key-Value to be faster.
-	exists := false
-	for i, ele := range h.Access.Elements {
-		if bytes.Equal(keyArray[:], ele.Key[:]) {
-			h.Access.Elements[i].NewValue = newValueArray
-			exists = true
-			break
-		}
-	}
-	if !exists {
-		h.Access.Elements = append(h.Access.Elements, TripleElement{
-			Key:            keyArray,
-			OldValue:       oldValueArray,
-			NewValue:       newValueArray,
-			OldValueExists: oldValueExists,
-		})
-	}
-
-	h.IntermediateKV[[verkle.KeySize]byte(key)] = [ValueSize]byte(value)
+	h.Access.Elements = append(h.Access.Elements, TripleElement{
+		Key:            key,
+		OldValue:       oldValueArray,
+		NewValue:       oldValueArray,
+		OldValueExists: oldValueExists,
+	})
+	i := len(h.Access.Elements) - 1
+	h.accessIndex[key] = i
+	return i
 }
 
-func (h *Header) get(key []byte, nodeResolverFn verkle.NodeResolverFn) []byte {
+func (h *Header) insert(key []byte, value []byte, nodeResolverFn verkle.NodeResolverFn) {
 	if len(key) != verkle.KeySize {
 		panic(fmt.Errorf("the length the key to insert bytes must be %d, current is: %d", verkle.KeySize, len(key)))
 	}
+	if len(value) != ValueSize {
+		panic(fmt.Errorf("the length the value must be %d, current is: %d", ValueSize, len(key)))
+	}
 
-	key32 := [verkle.KeySize]byte(key)
+	keyArray := [verkle.KeySize]byte(key)
+	newValueArray := [ValueSize]byte(value)
 
-	oldValue, err := h.Root.Get(key, nodeResolverFn)
-	if err != nil {
-		panic(err)
-	}
-	oldValueExists := len(oldValue) > 0
+	i := h.access(keyArray, nodeResolverFn)
+	h.Access.Elements[i].NewValue = newValueArray
 
-	var res [ValueSize]byte
-	var found bool
+	h.IntermediateKV[keyArray] = newValueArray
+}
 
-	if res, found = h.IntermediateKV[key32]; found {
-		// The value has been updated during the execution.
-	} else {
-		if oldValueExists {
-			res = [ValueSize]byte(oldValue)
-		} else {
-			res = defaultValue()
-		}
+func (h *Header) get(key []byte, nodeResolverFn verkle.NodeResolverFn) []byte {
+	if len(key) != verkle.KeySize {
+		panic(fmt.Errorf("the length the key to insert bytes must be %d, current is: %d", verkle.KeySize, len(key)))
 	}
 
-	// Record access
-	exists := false
-	for _, ele := range h.Access.Elements {
-		if bytes.Equal(key, ele.Key[:]) {
-			exists = true
-			break
-		}
-	}
-	if !exists {
-		h.Access.Elements = append(h.Access.Elements, TripleElement{
-			Key:            key32,
-			OldValue:       res,
-			NewValue:       res,
-			OldValueExists: oldValueExists,
-		})
-	}
+	// NewValue is the value written during the execution, or the old value if the key is only read.
+	i := h.access([verkle.KeySize]byte(key), nodeResolverFn)
+	res := h.Access.Elements[i].NewValue
 	return res[:]
 }
 
@@ -202,77 +187,202 @@ func (h *Header) GetBytes(key []byte) []byte {
 	return res
 }
 
+// Paging moves the header to the next block, the block hash is queried if queryHash is set or the journal is used.
+// Otherwise the hash is left empty, the hash of the previous block must not be carried to the next one.
+// The hash is queried first, so a failed query leaves the header untouched.
 func (h *Header) Paging(ordGetter getter.OrdGetter, queryHash bool, nodeResolverFn verkle.NodeResolverFn) error {
+	hash := ""
+	if queryHash || journal != nil {
+		var err error
+		hash, err = ordGetter.GetBlockHash(h.Height + 1)
+		if err != nil {
+			return err
+		}
+	}
+	return h.PagingWithHash(hash, nodeResolverFn)
+}
+
+// PagingWithHash is Paging with the known hash of the next block.
+func (h *Header) PagingWithHash(hash string, nodeResolverFn verkle.NodeResolverFn) error {
+	err := h.KV.Apply(h.IntermediateKV, nil)
+	if err != nil {
+		return err
+	}
 	for key, value := range h.IntermediateKV {
-		h.KV[key] = value
 		_ = h.Root.Insert(key[:], value[:], nodeResolverFn)
 	}
+	var entry JournalEntry
+	if journal != nil {
+		entry = newJournalEntry(h)
+	}
+	err = h.flushNodes(writtenKeys(h.IntermediateKV))
+	if err != nil {
+		return err
+	}
 
-	h.Access = AccessList{}
+	h.resetAccess()
 	h.IntermediateKV = KeyValueMap{}
 	// Update height and hash
 	h.Height++
+	h.Hash = hash
 	metrics.CurrentHeight.Set(float64(h.Height))
-	if queryHash {
-		hash, err := ordGetter.GetBlockHash(h.Height)
-		if err != nil {
-			return err
-		}
-		h.Hash = hash
+	if journal != nil {
+		entry.Hash = h.Hash
+		return journal.Append(entry)
 	}
 	return nil
 }
 
+// flushNodes flushes the nodes changed by the keys into the node store in use, the detached tree stays in memory.
+func (h *Header) flushNodes(keys [][verkle.KeySize]byte) error {
+	if nodeStore == nil || h.detached {
+		return nil
+	}
+	return nodeStore.Flush(h.Root, keys)
+}
+
+// Attach flushes the detached tree into the node store in use, the nodes of the previously served tree are dropped.
+// The caller must have stopped serving the previous tree, e.g. by holding the write lock of the queue.
+func (h *Header) Attach() error {
+	h.detached = false
+	return resetNodeStore(h)
+}
+
+func writtenKeys(kv KeyValueMap) [][verkle.KeySize]byte {
+	keys := make([][verkle.KeySize]byte, 0, len(kv))
+	for key := range kv {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 func (h *Header) GetHeight() uint {
 	return h.Height
 }
 
-func (h *Header) Serialize() (*bytes.Buffer, error) {
-	// TODO: Medium. Use a native database instead of a key-value store for the state management.
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	err := encoder.Encode(h.KV)
+// The number of key values in each encoded chunk of the serialized state.
+const serializeChunkSize = 1 << 16
+
+// Serialize writes the key values as a stream of chunks so the state is never held in memory at once.
+// It returns the number of key values written.
+func (h *Header) Serialize(w io.Writer) (uint64, error) {
+	encoder := gob.NewEncoder(w)
+	var count uint64
+	chunk := make(KeyValueMap, serializeChunkSize)
+	err := h.KV.Iterate(func(key [verkle.KeySize]byte, value [ValueSize]byte) error {
+		chunk[key] = value
+		count++
+		if len(chunk) < serializeChunkSize {
+			return nil
+		}
+		if err := encoder.Encode(chunk); err != nil {
+			return err
+		}
+		chunk = make(KeyValueMap, serializeChunkSize)
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	if len(chunk) > 0 {
+		if err := encoder.Encode(chunk); err != nil {
+			return 0, err
+		}
 	}
-	return &buffer, nil
+	return count, nil
 }
 
-func (h *Header) OrderedKeys() [][verkle.KeySize]byte {
-	keys := make([][verkle.KeySize]byte, 0, len(h.KV))
-	for key := range h.KV {
+func (h *Header) OrderedKeys() ([][verkle.KeySize]byte, error) {
+	keys := make([][verkle.KeySize]byte, 0)
+	err := h.KV.Iterate(func(key [verkle.KeySize]byte, _ [ValueSize]byte) error {
 		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	sort.Slice(keys, func(i, j int) bool {
 		return string(keys[i][:]) < string(keys[j][:])
 	})
-	return keys
+	return keys, nil
 }
 
-func Deserialize(buffer *bytes.Buffer, height uint, nodeResolverFn verkle.NodeResolverFn) (*Header, error) {
-	var kv KeyValueMap
-	decoder := gob.NewDecoder(buffer)
-	err := decoder.Decode(&kv)
+// DeserializeTree rebuilds the read only header from the chunks written by Serialize without a store,
+// the key values are served by the tree. It returns the number of key values read.
+func DeserializeTree(reader io.Reader, height uint) (*Header, uint64, error) {
+	var count uint64
+	decoder := gob.NewDecoder(reader)
+	started := time.Now()
+	root, err := BuildTree(func(fn func(key [verkle.KeySize]byte, value [ValueSize]byte) error) error {
+		for {
+			var kv KeyValueMap
+			err := decoder.Decode(&kv)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			for key, value := range kv {
+				if err := fn(key, value); err != nil {
+					return err
+				}
+			}
+			count += uint64(len(kv))
+		}
+	}, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	root := verkle.New()
-	for k, v := range kv {
-		err := root.Insert(k[:], v[:], nodeResolverFn)
+	metrics.ObserveTreeBuild("deserialize", started)
+	return &Header{
+		Root:           root,
+		KV:             treeStore{root: root},
+		Height:         height,
+		Access:         AccessList{},
+		IntermediateKV: KeyValueMap{},
+		detached:       true,
+	}, count, nil
+}
+
+// Deserialize rebuilds the header from the chunks written by Serialize, all key values are written into the store.
+// It returns the number of key values read.
+func Deserialize(reader io.Reader, height uint, store StateStore, nodeResolverFn verkle.NodeResolverFn) (*Header, uint64, error) {
+	err := store.Clear()
+	if err != nil {
+		return nil, 0, err
+	}
+	var count uint64
+	decoder := gob.NewDecoder(reader)
+	for {
+		var kv KeyValueMap
+		err := decoder.Decode(&kv)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		err = store.Apply(kv, nil)
 		if err != nil {
-			return nil, nil
+			return nil, 0, err
 		}
+		count += uint64(len(kv))
+	}
+	started := time.Now()
+	root, err := BuildTree(store.Iterate, nodeResolverFn)
+	if err != nil {
+		return nil, 0, err
 	}
-	// The call of Commit is necessary to refresh the root commit.
-	root.Commit()
+	metrics.ObserveTreeBuild("deserialize", started)
 
 	myHeader := Header{
 		Root:           root,
-		KV:             kv,
+		KV:             store,
 		Height:         height,
 		Hash:           "",
 		Access:         AccessList{},
 		IntermediateKV: KeyValueMap{},
 	}
-	return &myHeader, nil
+	return &myHeader, count, nil
 }
@@ -0,0 +1,112 @@
+package stateless
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+	"github.com/holiman/uint256"
+)
+
+func newTestHeader() *Header {
+	return &Header{Root: verkle.New(), KV: NewMemoryStore(), Access: AccessList{}, IntermediateKV: KeyValueMap{}}
+}
+
+func TestHeaderAccess(t *testing.T) {
+	header := newTestHeader()
+	existing := GetTickHash("ordi", 0)
+	value := uint256.NewInt(7).Bytes32()
+	if err := header.Root.Insert(existing, value[:], nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := GetTickHash("sats", 0)
+	header.GetUInt256(fresh)
+	header.InsertUInt256(existing, uint256.NewInt(8))
+	header.InsertUInt256(fresh, uint256.NewInt(1))
+	if value := header.GetUInt256(existing); value.Uint64() != 8 {
+		t.Fatal("unexpected value after the insert", value)
+	}
+	readOnly := GetTickHash("meme", 0)
+	if value := header.GetUInt256(readOnly); !value.IsZero() {
+		t.Fatal("unexpected value of the absent key", value)
+	}
+
+	expected := []TripleElement{
+		{Key: [verkle.KeySize]byte(fresh), NewValue: uint256.NewInt(1).Bytes32()},
+		{Key: [verkle.KeySize]byte(existing), OldValue: uint256.NewInt(7).Bytes32(), NewValue: uint256.NewInt(8).Bytes32(), OldValueExists: true},
+		{Key: [verkle.KeySize]byte(readOnly)},
+	}
+	if len(header.Access.Elements) != len(expected) {
+		t.Fatal("unexpected length of the access list", len(header.Access.Elements))
+	}
+	for i, ele := range header.Access.Elements {
+		if ele != expected[i] {
+			t.Fatalf("unexpected access at %d: %+v", i, ele)
+		}
+	}
+
+	// The index is cleared along with the access list.
+	header.resetAccess()
+	header.GetUInt256(existing)
+	if len(header.Access.Elements) != 1 || header.Access.Elements[0].Key != [verkle.KeySize]byte(existing) {
+		t.Fatal("the access list is not rebuilt", header.Access.Elements)
+	}
+}
+
+func TestStateReader(t *testing.T) {
+	header := newTestHeader()
+	key := GetTickHash("ordi", 0)
+	header.InsertUInt256(key, uint256.NewInt(7))
+	if err := header.PagingWithHash("hash1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := header.Reader()
+	if value := reader.GetUInt256(key); value.Uint64() != 7 {
+		t.Fatal("unexpected value", value)
+	}
+	if value := reader.GetUInt256(GetTickHash("sats", 0)); !value.IsZero() {
+		t.Fatal("unexpected value of the absent key", value)
+	}
+	if len(header.Access.Elements) != 0 || header.accessIndex != nil {
+		t.Fatal("the reads are recorded", header.Access.Elements)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("wrote to the read only state")
+		}
+	}()
+	reader.InsertUInt256(key, uint256.NewInt(8))
+}
+
+func TestHeaderPagingHash(t *testing.T) {
+	header := newTestHeader()
+	if err := header.PagingWithHash("hash1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if header.Height != 1 || header.Hash != "hash1" {
+		t.Fatal("unexpected header after the paging", header.Height, header.Hash)
+	}
+	// The hash of the previous block is not carried forward.
+	if err := header.Paging(nil, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if header.Height != 2 || header.Hash != "" {
+		t.Fatal("the hash is carried to the next block", header.Height, header.Hash)
+	}
+}
+
+// BenchmarkHeaderAccess simulates a block full of mints, each touching its own balance keys and the shared tick keys.
+func BenchmarkHeaderAccess(b *testing.B) {
+	const mints = 20000
+	for range b.N {
+		header := newTestHeader()
+		for i := range mints {
+			supply := GetTickHash("ordi", byte(RemainingSupply))
+			header.InsertUInt256(supply, header.GetUInt256(supply).AddUint64(header.GetUInt256(supply), 1))
+			balance := GetTickHash("ordi", byte(i))
+			balance[0] = byte(i >> 8)
+			header.InsertUInt256(balance, header.GetUInt256(balance).AddUint64(header.GetUInt256(balance), 1))
+		}
+	}
+}
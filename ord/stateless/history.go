@@ -0,0 +1,181 @@
+package stateless
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-verkle"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// History rebuilds the states at past heights from the nearest snapshot and the journal.
+// The rebuilt states are detached from the running state, only their trees are kept in memory within a memory budget.
+// The cached states must be invalidated after a reorg, see Invalidate.
+type History struct {
+	snapshots *SnapshotManager
+	cache     *lru.Cache[uint, *Header]
+	// The estimated memory of the cached states, see treeMemory.
+	budget uint64
+	used   uint64
+	sizes  map[uint]uint64
+	// Only one state is rebuilt at a time.
+	sync.Mutex
+}
+
+// NewHistory keeps the rebuilt states up to budget bytes, the least recently used ones are evicted first.
+func NewHistory(snapshots *SnapshotManager, budget uint64) (*History, error) {
+	h := &History{snapshots: snapshots, budget: budget, sizes: make(map[uint]uint64)}
+	// The entries are bounded by the budget, the count only bounds the index.
+	cache, err := lru.NewWithEvict[uint, *Header](math.MaxInt32, func(height uint, _ *Header) {
+		h.used -= h.sizes[height]
+		delete(h.sizes, height)
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.cache = cache
+	return h, nil
+}
+
+// StateAt returns the state at height, the returned header must be used read-only.
+func (h *History) StateAt(height uint) (*Header, error) {
+	if header, found := h.cache.Get(height); found {
+		return header, nil
+	}
+	h.Lock()
+	defer h.Unlock()
+	if header, found := h.cache.Get(height); found {
+		return header, nil
+	}
+	header, err := h.rebuild(height)
+	if err != nil {
+		return nil, err
+	}
+	size := treeMemory(header.Root)
+	if size > h.budget {
+		log.Printf("Serve the state at height %d without caching, its %d bytes exceed the budget", height, size)
+		return header, nil
+	}
+	for h.used+size > h.budget {
+		h.cache.RemoveOldest()
+	}
+	h.cache.Add(height, header)
+	h.sizes[height] = size
+	h.used += size
+	return header, nil
+}
+
+// Invalidate drops the cached states at or above height, they are on the chain abandoned by a reorg from height.
+// A rebuild in progress is waited for, so it can't cache a state of the abandoned chain afterwards.
+func (h *History) Invalidate(height uint) {
+	h.Lock()
+	defer h.Unlock()
+	for _, cached := range h.cache.Keys() {
+		if cached >= height {
+			h.cache.Remove(cached)
+		}
+	}
+}
+
+// rebuild tries the snapshots from the nearest to the farthest, it moves the snapshot to height through the journal.
+func (h *History) rebuild(height uint) (*Header, error) {
+	heights, err := h.snapshots.Heights()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(heights, func(i, j int) bool {
+		return distance(heights[i], height) < distance(heights[j], height)
+	})
+	for _, snapshotHeight := range heights {
+		if snapshotHeight != height && journal == nil {
+			// Only the states at the snapshot heights are available without the journal.
+			continue
+		}
+		header, _, err := LoadSnapshotTree(h.snapshots.Path(snapshotHeight))
+		if err != nil {
+			log.Printf("Skip the invalid snapshot at height %d: %v", snapshotHeight, err)
+			continue
+		}
+		if snapshotHeight != height {
+			err = journal.moveTree(header, height)
+		}
+		if err != nil {
+			log.Printf("Failed to move the snapshot at height %d to height %d: %v", snapshotHeight, height, err)
+			continue
+		}
+		return header, nil
+	}
+	return nil, fmt.Errorf("the state at height %d isn't available from the snapshots and the journal", height)
+}
+
+func distance(a, b uint) uint {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// The estimated sizes of the nodes of an in-memory verkle tree: the children or the value slots,
+// the commitments, and the bookkeeping of the node.
+const (
+	pointMemory    = 4 * 32
+	internalMemory = verkle.NodeWidth*16 + 2*pointMemory + 64
+	leafMemory     = verkle.NodeWidth*24 + verkle.StemSize + 3*pointMemory + 64
+)
+
+// treeMemory estimates the memory held by the resident nodes of the tree.
+func treeMemory(node verkle.VerkleNode) uint64 {
+	switch n := node.(type) {
+	case *verkle.InternalNode:
+		size := uint64(internalMemory)
+		for _, child := range n.Children() {
+			size += treeMemory(child)
+		}
+		return size
+	case *verkle.LeafNode:
+		size := uint64(leafMemory)
+		for _, value := range n.Values() {
+			size += uint64(len(value))
+		}
+		return size
+	default:
+		return 0
+	}
+}
+
+var errReadOnlyTree = errors.New("the historical state is read only")
+
+// treeStore serves the key values of a historical state from its tree, so the state needs no copy of the key values.
+type treeStore struct {
+	root verkle.VerkleNode
+}
+
+func (s treeStore) Get(key [verkle.KeySize]byte) ([ValueSize]byte, bool, error) {
+	var value [ValueSize]byte
+	stored, err := s.root.Get(key[:], nil)
+	if err != nil || len(stored) == 0 {
+		return value, false, err
+	}
+	copy(value[:], stored)
+	return value, true, nil
+}
+
+func (s treeStore) Apply(puts KeyValueMap, deletes [][verkle.KeySize]byte) error {
+	return errReadOnlyTree
+}
+
+func (s treeStore) Iterate(fn func(key [verkle.KeySize]byte, value [ValueSize]byte) error) error {
+	return errReadOnlyTree
+}
+
+func (s treeStore) Clear() error {
+	return errReadOnlyTree
+}
+
+func (s treeStore) Close() error {
+	return nil
+}
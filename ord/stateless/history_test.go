@@ -0,0 +1,171 @@
+package stateless
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+	"github.com/holiman/uint256"
+)
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	j, err := NewJournal(filepath.Join(dir, "journal.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	UseJournal(j)
+	defer UseJournal(nil)
+
+	snapshots := NewSnapshotManager(dir, 3, RetentionPolicy{})
+	header := &Header{Root: verkle.New(), KV: NewMemoryStore(), Access: AccessList{}, IntermediateKV: KeyValueMap{}}
+	commits := [][32]byte{header.Root.Commit().Bytes()}
+	for block := uint(1); block <= 7; block++ {
+		for i := range 2 {
+			key := GetTickHash("ordi", byte(i))
+			key[0] = byte(block + uint(i))
+			header.InsertUInt256(key, uint256.NewInt(uint64(block)))
+		}
+		if err := header.Paging(journalGetter{}, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, header.Root.Commit().Bytes())
+		if snapshots.Due(block) {
+			if err := snapshots.StoreHeader(journalGetter{}, header); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	history, err := NewHistory(snapshots, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, height := range []uint{3, 4, 5, 7, 4} {
+		state, err := history.StateAt(height)
+		if err != nil {
+			t.Fatal(height, err)
+		}
+		if state.Height != height || state.Root.Commit().Bytes() != commits[height] {
+			t.Fatal("unexpected historical state", height, state.Height)
+		}
+		// The key values are read from the tree.
+		key := GetTickHash("ordi", 1)
+		key[0] = byte(height + 1)
+		if value := state.Reader().GetUInt256(key); value.Uint64() != uint64(height) {
+			t.Fatal("unexpected historical value", height, value)
+		}
+	}
+	if history.cache.Len() != 4 {
+		t.Fatal("unexpected cached states", history.cache.Keys())
+	}
+
+	// The budget holds a single state, the older ones are evicted.
+	state, err := history.StateAt(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := treeMemory(state.Root)
+	history, err = NewHistory(snapshots, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, height := range []uint{4, 5} {
+		if _, err := history.StateAt(height); err != nil {
+			t.Fatal(height, err)
+		}
+	}
+	if keys := history.cache.Keys(); len(keys) != 1 || keys[0] != 5 || history.used > size {
+		t.Fatal("unexpected cached states within the budget", keys, history.used)
+	}
+
+	// The journal is pruned below the oldest snapshot.
+	if _, err := history.StateAt(1); err == nil {
+		t.Fatal("the state below the oldest snapshot is served")
+	}
+
+	UseJournal(nil)
+	history, err = NewHistory(snapshots, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := history.StateAt(6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := history.StateAt(5); err == nil {
+		t.Fatal("the state between the snapshots is served without the journal")
+	}
+}
+
+func TestHistoryReorg(t *testing.T) {
+	dir := t.TempDir()
+	j, err := NewJournal(filepath.Join(dir, "journal.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	UseJournal(j)
+	defer UseJournal(nil)
+
+	snapshots := NewSnapshotManager(dir, 3, RetentionPolicy{})
+	header := &Header{Root: verkle.New(), KV: NewMemoryStore(), Access: AccessList{}, IntermediateKV: KeyValueMap{}}
+	execute := func(from, to uint, fork byte) {
+		for block := from; block <= to; block++ {
+			key := GetTickHash("ordi", 0)
+			key[0] = byte(block)
+			header.InsertUInt256(key, uint256.NewInt(uint64(block)+uint64(fork)))
+			if err := header.PagingWithHash(fmt.Sprintf("%d-%d", fork, block), nil); err != nil {
+				t.Fatal(err)
+			}
+			if snapshots.Due(block) {
+				if err := snapshots.StoreHeader(journalGetter{}, header); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	execute(1, 5, 0)
+
+	history, err := NewHistory(snapshots, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, height := range []uint{4, 5} {
+		if _, err := history.StateAt(height); err != nil {
+			t.Fatal(height, err)
+		}
+	}
+
+	// The blocks from 4 are replaced by the fork.
+	if err := j.Rollback(header, 3); err != nil {
+		t.Fatal(err)
+	}
+	execute(4, 5, 1)
+	history.Invalidate(4)
+	if keys := history.cache.Keys(); len(keys) != 0 {
+		t.Fatal("the states of the abandoned chain are cached", keys)
+	}
+
+	state, err := history.StateAt(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := GetTickHash("ordi", 0)
+	key[0] = 5
+	if state.Hash != "1-5" || state.Root.Commit().Bytes() != header.Root.Commit().Bytes() {
+		t.Fatal("unexpected historical state after the reorg", state.Hash)
+	}
+	if value := state.Reader().GetUInt256(key); value.Uint64() != 6 {
+		t.Fatal("unexpected historical value after the reorg", value)
+	}
+	// The rollback from the fork is checked against the new chain too.
+	state, err = history.StateAt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Hash != "1-4" {
+		t.Fatal("unexpected historical state after the reorg", state.Hash)
+	}
+}
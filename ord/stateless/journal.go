@@ -0,0 +1,456 @@
+package stateless
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-verkle"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/RiemaLabs/modular-indexer-committee/internal/metrics"
+	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
+)
+
+var journalBucket = []byte("journal")
+
+// JournalEntry records the state difference made by the block at Height.
+type JournalEntry struct {
+	Height uint
+	Hash   string
+	// The commitment of the state after the block.
+	VerkleCommit [32]byte
+	// All key values accessed by the block, with the values before and after the block.
+	Access AccessList
+	// The keys written by the block, the others in Access were only read.
+	Writes [][verkle.KeySize]byte
+}
+
+// newJournalEntry records the executed block of the header, its key values must have been inserted into the tree.
+func newJournalEntry(h *Header) JournalEntry {
+	writes := make([][verkle.KeySize]byte, 0, len(h.IntermediateKV))
+	for _, ele := range h.Access.Elements {
+		if _, found := h.IntermediateKV[ele.Key]; found {
+			writes = append(writes, ele.Key)
+		}
+	}
+	return JournalEntry{
+		Height:       h.Height + 1,
+		Access:       h.Access,
+		VerkleCommit: h.Root.Commit().Bytes(),
+		Writes:       writes,
+	}
+}
+
+// written returns the accessed elements whose keys were written by the block.
+func (e *JournalEntry) written() []TripleElement {
+	writes := make(map[[verkle.KeySize]byte]struct{}, len(e.Writes))
+	for _, key := range e.Writes {
+		writes[key] = struct{}{}
+	}
+	elements := make([]TripleElement, 0, len(e.Writes))
+	for _, ele := range e.Access.Elements {
+		if _, found := writes[ele.Key]; found {
+			elements = append(elements, ele)
+		}
+	}
+	return elements
+}
+
+// Journal is an on-disk log of the per-block state differences, keyed by the block height.
+// With the journal, the state can be moved forward and backward between any two recorded heights
+// without querying the OPI database or executing the blocks.
+type Journal struct {
+	db *bolt.DB
+}
+
+func NewJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0666, &bolt.Options{
+		Timeout:      time.Second,
+		FreelistType: bolt.FreelistMapType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the journal at %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(journalBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Journal{db: db}, nil
+}
+
+func journalKey(height uint) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(height))
+	return key
+}
+
+// Append writes the entry and drops all entries above its height, they belong to an abandoned chain.
+func (j *Journal) Append(entry JournalEntry) error {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(entry)
+	if err != nil {
+		return err
+	}
+	return j.db.Update(func(tx *bolt.Tx) error {
+		err := truncateAbove(tx, entry.Height)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(journalBucket).Put(journalKey(entry.Height), buf.Bytes())
+	})
+}
+
+func truncateAbove(tx *bolt.Tx, height uint) error {
+	c := tx.Bucket(journalBucket).Cursor()
+	for k, _ := c.Seek(journalKey(height + 1)); k != nil; k, _ = c.Next() {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Truncate drops all entries above height.
+func (j *Journal) Truncate(height uint) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		return truncateAbove(tx, height)
+	})
+}
+
+// Prune drops all entries below height. The entry at height is kept, it checks the rollback to height.
+func (j *Journal) Prune(height uint) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(journalBucket).Cursor()
+		end := journalKey(height)
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.Next() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns the entry at height, it returns false if the entry doesn't exist.
+func (j *Journal) Get(height uint) (JournalEntry, bool, error) {
+	var entry JournalEntry
+	var found bool
+	err := j.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(journalBucket).Get(journalKey(height))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return gob.NewDecoder(bytes.NewReader(v)).Decode(&entry)
+	})
+	return entry, found, err
+}
+
+// Range returns the lowest and highest recorded heights, ok is false if the journal is empty.
+func (j *Journal) Range() (first uint, last uint, ok bool, err error) {
+	err = j.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(journalBucket).Cursor()
+		k, _ := c.First()
+		if k == nil {
+			return nil
+		}
+		first = uint(binary.BigEndian.Uint64(k))
+		k, _ = c.Last()
+		last = uint(binary.BigEndian.Uint64(k))
+		ok = true
+		return nil
+	})
+	return first, last, ok, err
+}
+
+func (j *Journal) mustGet(height uint) (JournalEntry, error) {
+	entry, found, err := j.Get(height)
+	if err != nil {
+		return entry, err
+	}
+	if !found {
+		return entry, fmt.Errorf("the journal entry at height %d is missing", height)
+	}
+	return entry, nil
+}
+
+// Replay moves the header forward to height by applying the recorded blocks one by one,
+// the commitment is checked against the journal after each block.
+func (j *Journal) Replay(header *Header, height uint) error {
+	return j.replay(header, height, true)
+}
+
+// replay flushes the tree into the node store only if flush is set, the detached headers must not touch the node store.
+func (j *Journal) replay(header *Header, height uint, flush bool) error {
+	for i := header.Height + 1; i <= height; i++ {
+		entry, err := j.mustGet(i)
+		if err != nil {
+			return err
+		}
+		puts := KeyValueMap{}
+		for _, ele := range entry.written() {
+			puts[ele.Key] = ele.NewValue
+		}
+		err = header.KV.Apply(puts, nil)
+		if err != nil {
+			return err
+		}
+		for key, value := range puts {
+			err := header.Root.Insert(key[:], value[:], NodeResolveFn)
+			if err != nil {
+				return err
+			}
+		}
+		commit := header.Root.Commit().Bytes()
+		if commit != entry.VerkleCommit {
+			return fmt.Errorf("mismatched commitment after replaying the block %d: %s, expected: %s", i,
+				base64.StdEncoding.EncodeToString(commit[:]), base64.StdEncoding.EncodeToString(entry.VerkleCommit[:]))
+		}
+		if flush {
+			err := header.flushNodes(entry.Writes)
+			if err != nil {
+				return err
+			}
+		}
+		header.Height = i
+		header.Hash = entry.Hash
+	}
+	header.resetAccess()
+	header.IntermediateKV = KeyValueMap{}
+	return nil
+}
+
+// Rollback moves the header backward to height by restoring the old values of the recorded blocks.
+func (j *Journal) Rollback(header *Header, height uint) error {
+	err := j.rollback(header, height)
+	if err != nil {
+		return err
+	}
+	metrics.CurrentHeight.Set(float64(header.Height))
+	return resetNodeStore(header)
+}
+
+// rollback restores the store block by block, the verkle tree is rebuilt by Rollingback
+// from the last restored block since the deletion isn't supported by the tree.
+func (j *Journal) rollback(header *Header, height uint) error {
+	if height > header.Height {
+		return fmt.Errorf("cannot rollback to height %d above the current height %d", height, header.Height)
+	}
+	if height == header.Height {
+		return nil
+	}
+	var last JournalEntry
+	for i := header.Height; i > height; i-- {
+		entry, err := j.mustGet(i)
+		if err != nil {
+			return err
+		}
+		if i == height+1 {
+			// The store is still at height + 1, which Rollingback expects.
+			last = entry
+			break
+		}
+		err = restoreOldValues(header.KV, &entry)
+		if err != nil {
+			return err
+		}
+	}
+	root, _ := Rollingback(header, &DiffState{Height: height, Access: last.Access})
+	err := restoreOldValues(header.KV, &last)
+	if err != nil {
+		return err
+	}
+
+	commit := root.Commit().Bytes()
+	// The entry at the target height is absent if it is the height of the initial state.
+	entry, found, err := j.Get(height)
+	if err != nil {
+		return err
+	}
+	if found && commit != entry.VerkleCommit {
+		return fmt.Errorf("mismatched commitment after rolling back to the block %d: %s, expected: %s", height,
+			base64.StdEncoding.EncodeToString(commit[:]), base64.StdEncoding.EncodeToString(entry.VerkleCommit[:]))
+	}
+	header.Root = root
+	header.Height = height
+	header.Hash = entry.Hash
+	header.resetAccess()
+	header.IntermediateKV = KeyValueMap{}
+	return nil
+}
+
+// moveTree moves the tree of the read only header to height, the header has no store to update.
+// The blocks are applied to the tree in place one by one, and the commitment is checked against the journal after each one.
+func (j *Journal) moveTree(header *Header, height uint) error {
+	for header.Height < height {
+		entry, err := j.mustGet(header.Height + 1)
+		if err != nil {
+			return err
+		}
+		for _, ele := range entry.written() {
+			err := header.Root.Insert(ele.Key[:], ele.NewValue[:], nil)
+			if err != nil {
+				return err
+			}
+		}
+		err = j.checkTree(header, entry)
+		if err != nil {
+			return err
+		}
+	}
+	for header.Height > height {
+		entry, err := j.mustGet(header.Height)
+		if err != nil {
+			return err
+		}
+		puts := KeyValueMap{}
+		deletes := make([][verkle.KeySize]byte, 0)
+		for _, ele := range entry.written() {
+			if ele.OldValueExists {
+				puts[ele.Key] = ele.OldValue
+			} else {
+				deletes = append(deletes, ele.Key)
+			}
+		}
+		err = RollbackTree(header.Root, puts, deletes, nil)
+		if err != nil {
+			return err
+		}
+		// The entry below is absent if it is the height of the initial state.
+		below, found, err := j.Get(header.Height - 1)
+		if err != nil {
+			return err
+		}
+		if !found {
+			header.Height--
+			header.Hash = ""
+			continue
+		}
+		err = j.checkTree(header, below)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTree moves the header to the height of the entry once the commitment of its tree matches the entry.
+func (j *Journal) checkTree(header *Header, entry JournalEntry) error {
+	commit := header.Root.Commit().Bytes()
+	if commit != entry.VerkleCommit {
+		return fmt.Errorf("mismatched commitment at the block %d: %s, expected: %s", entry.Height,
+			base64.StdEncoding.EncodeToString(commit[:]), base64.StdEncoding.EncodeToString(entry.VerkleCommit[:]))
+	}
+	header.Height = entry.Height
+	header.Hash = entry.Hash
+	return nil
+}
+
+func restoreOldValues(store StateStore, entry *JournalEntry) error {
+	puts := KeyValueMap{}
+	deletes := make([][verkle.KeySize]byte, 0)
+	for _, ele := range entry.written() {
+		if ele.OldValueExists {
+			puts[ele.Key] = ele.OldValue
+		} else {
+			deletes = append(deletes, ele.Key)
+		}
+	}
+	return store.Apply(puts, deletes)
+}
+
+// Resume replays the journal on the header up to maxHeight, so the restart doesn't execute the recorded blocks again.
+// The entries on an abandoned chain are dropped, they are found by checking the block hashes of O(log n) entries with the getter.
+func (j *Journal) Resume(ordGetter getter.OrdGetter, header *Header, maxHeight uint) error {
+	_, last, ok, err := j.Range()
+	if err != nil || !ok {
+		return err
+	}
+	target := min(last, maxHeight)
+	// Stop at the first gap, the entries after it can't be applied.
+	for i := header.Height + 1; i <= target; i++ {
+		_, found, err := j.Get(i)
+		if err != nil {
+			return err
+		}
+		if !found {
+			target = i - 1
+			break
+		}
+	}
+	// The entries match the chain up to the fork and mismatch above it, so the highest matching entry is
+	// searched by halves instead of querying the hash of every block.
+	var searchErr error
+	matched := sort.Search(int(target-header.Height), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		height := target - uint(i)
+		entry, err := j.mustGet(height)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		hash, err := ordGetter.GetBlockHash(height)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		if hash != entry.Hash {
+			log.Printf("Mismatched block hash of the journal entry at height %d: %s, expected %s", height, entry.Hash, hash)
+			return false
+		}
+		return true
+	})
+	if searchErr != nil {
+		return searchErr
+	}
+	dropped := matched > 0
+	if dropped {
+		log.Printf("Drop the journal entries from height %d to %d", target-uint(matched)+1, target)
+	}
+	target -= uint(matched)
+	if dropped {
+		err := j.Truncate(target)
+		if err != nil {
+			return err
+		}
+	}
+	if target <= header.Height {
+		return nil
+	}
+	log.Printf("Replay the journal from height %d to %d", header.Height, target)
+	err = j.Replay(header, target)
+	if err != nil {
+		return err
+	}
+	metrics.CurrentHeight.Set(float64(header.Height))
+	return nil
+}
+
+func (j *Journal) Close() error {
+	return j.db.Close()
+}
+
+// ResumeJournal resumes the header from the journal in use, it does nothing without the journal.
+func ResumeJournal(ordGetter getter.OrdGetter, header *Header, maxHeight uint) error {
+	if journal == nil {
+		return nil
+	}
+	return journal.Resume(ordGetter, header, maxHeight)
+}
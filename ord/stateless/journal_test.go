@@ -0,0 +1,100 @@
+package stateless
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+	"github.com/holiman/uint256"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
+)
+
+// journalGetter serves the block hashes, the hashes above forkHeight belong to another chain.
+type journalGetter struct {
+	forkHeight uint
+}
+
+func (g journalGetter) GetLatestBlockHeight() (uint, error) {
+	return 0, nil
+}
+
+func (g journalGetter) GetBlockHash(blockHeight uint) (string, error) {
+	if g.forkHeight != 0 && blockHeight > g.forkHeight {
+		return fmt.Sprintf("fork%d", blockHeight), nil
+	}
+	return fmt.Sprintf("hash%d", blockHeight), nil
+}
+
+func (g journalGetter) GetOrdTransfers(blockHeight uint) ([]getter.OrdTransfer, error) {
+	return nil, nil
+}
+
+func TestJournal(t *testing.T) {
+	j, err := NewJournal(filepath.Join(t.TempDir(), "journal.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	UseJournal(j)
+	defer UseJournal(nil)
+
+	header := &Header{Root: verkle.New(), KV: NewMemoryStore(), Access: AccessList{}, IntermediateKV: KeyValueMap{}}
+	commits := [][32]byte{header.Root.Commit().Bytes()}
+	for block := uint(1); block <= 5; block++ {
+		for i := range 3 {
+			key := GetTickHash("ordi", byte(i))
+			key[0] = byte(block + uint(i))
+			header.InsertUInt256(key, uint256.NewInt(uint64(block)))
+		}
+		// Only read, the key must not be written by the replay.
+		header.GetUInt256(GetTickHash("sats", 0))
+		if err := header.Paging(journalGetter{}, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, header.Root.Commit().Bytes())
+	}
+
+	if err := j.Rollback(header, 2); err != nil {
+		t.Fatal(err)
+	}
+	if header.Height != 2 || header.Root.Commit().Bytes() != commits[2] {
+		t.Fatal("unexpected state after the rollback", header.Height)
+	}
+	if err := j.Replay(header, 5); err != nil {
+		t.Fatal(err)
+	}
+	if header.Height != 5 || header.Hash != "hash5" || header.Root.Commit().Bytes() != commits[5] {
+		t.Fatal("unexpected state after the replay", header.Height, header.Hash)
+	}
+	if err := j.Rollback(header, 0); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := header.OrderedKeys()
+	if err != nil || len(keys) != 0 || header.Root.Commit().Bytes() != commits[0] {
+		t.Fatal("unexpected state after the rollback to the initial state", keys, err)
+	}
+
+	if err := j.Resume(journalGetter{}, header, 4); err != nil {
+		t.Fatal(err)
+	}
+	if header.Height != 4 || header.Root.Commit().Bytes() != commits[4] {
+		t.Fatal("unexpected state after the resume", header.Height)
+	}
+
+	restarted := &Header{Root: verkle.New(), KV: NewMemoryStore(), Access: AccessList{}, IntermediateKV: KeyValueMap{}}
+	if err := j.Resume(journalGetter{forkHeight: 3}, restarted, 5); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Height != 3 || restarted.Root.Commit().Bytes() != commits[3] {
+		t.Fatal("unexpected state after the resume on the fork", restarted.Height)
+	}
+	if _, last, _, err := j.Range(); err != nil || last != 3 {
+		t.Fatal("the entries on the abandoned chain are not dropped", last, err)
+	}
+}
+
+func (g journalGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]getter.OrdTransfer, error) {
+	return make([][]getter.OrdTransfer, toHeight-fromHeight+1), nil
+}
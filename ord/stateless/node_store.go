@@ -0,0 +1,185 @@
+package stateless
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-verkle"
+	lru "github.com/hashicorp/golang-lru/v2"
+	bolt "go.etcd.io/bbolt"
+)
+
+var nodeBucket = []byte("nodes")
+
+// The inner nodes down to this depth always stay in memory, at most 1 + 256 + 256^2 of them.
+// The deeper nodes and all leaves are evicted after they are flushed to the NodeStore.
+const nodeFlushDepth uint8 = 2
+
+// NodeStore keeps the serialized verkle nodes on the disk, keyed by their paths in the tree.
+// The recently resolved nodes are cached in memory with an LRU budget.
+type NodeStore struct {
+	db    *bolt.DB
+	cache *lru.Cache[string, []byte]
+}
+
+func NewNodeStore(path string, cacheSize int) (*NodeStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	cache, err := lru.New[string, []byte](cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0666, &bolt.Options{
+		Timeout:      time.Second,
+		FreelistType: bolt.FreelistMapType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the node store at %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(nodeBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &NodeStore{db: db, cache: cache}, nil
+}
+
+// Resolve implements verkle.NodeResolverFn.
+func (s *NodeStore) Resolve(path []byte) ([]byte, error) {
+	if serialized, found := s.cache.Get(string(path)); found {
+		return serialized, nil
+	}
+	var serialized []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(nodeBucket).Get(path)
+		if v == nil {
+			return fmt.Errorf("the verkle node at path %x is missing from the node store", path)
+		}
+		// The value is only valid during the transaction.
+		serialized = append([]byte{}, v...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Add(string(path), serialized)
+	return serialized, nil
+}
+
+// Flush commits the root and writes the nodes changed since the last flush to the disk, they are the nodes on the paths
+// of the written keys and the leaves moved under them by the splits. The nodes below nodeFlushDepth are replaced by
+// hashed nodes and will be resolved on demand, the unchanged ones are already on the disk.
+// The tree must not be read during the flush, the caller holds the write lock of the queue if it is served.
+func (s *NodeStore) Flush(root verkle.VerkleNode, keys [][verkle.KeySize]byte) error {
+	return s.flush(root, keys, false)
+}
+
+// FlushAll writes all resident nodes, e.g. after the tree is rebuilt from the key values.
+func (s *NodeStore) FlushAll(root verkle.VerkleNode) error {
+	return s.flush(root, nil, true)
+}
+
+func (s *NodeStore) flush(root verkle.VerkleNode, keys [][verkle.KeySize]byte, all bool) error {
+	internal, ok := root.(*verkle.InternalNode)
+	if !ok {
+		return fmt.Errorf("the root of the verkle tree must be an internal node")
+	}
+	// The root must be committed before its children are hashed.
+	internal.Commit()
+
+	nodes := make(map[string][]byte)
+	err := collectNodes(internal, nil, keys, all, nodes)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(nodeBucket)
+		for path, serialized := range nodes {
+			if err := bucket.Put([]byte(path), serialized); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for path, serialized := range nodes {
+		if s.cache.Contains(path) {
+			s.cache.Add(path, serialized)
+		}
+	}
+	return nil
+}
+
+// collectNodes serializes the changed children of the node at path and evicts the deep ones.
+// The keys are the written keys under path, all of the children are changed if all is set.
+func collectNodes(node *verkle.InternalNode, path []byte, keys [][verkle.KeySize]byte, all bool, nodes map[string][]byte) error {
+	depth := len(path)
+	byChild := make(map[byte][][verkle.KeySize]byte)
+	for _, key := range keys {
+		byChild[key[depth]] = append(byChild[key[depth]], key)
+	}
+	for i, child := range node.Children() {
+		childKeys := byChild[byte(i)]
+		childPath := append(path[:depth:depth], byte(i))
+		switch c := child.(type) {
+		case *verkle.InternalNode:
+			if all || len(childKeys) > 0 {
+				serialized, err := c.Serialize()
+				if err != nil {
+					return err
+				}
+				nodes[string(childPath)] = serialized
+			}
+			err := collectNodes(c, childPath, childKeys, all, nodes)
+			if err != nil {
+				return err
+			}
+			if len(childPath) <= int(nodeFlushDepth) {
+				continue
+			}
+		case *verkle.LeafNode:
+			// A leaf under a changed node may have been moved there by a split, so it is written as well.
+			if all || len(keys) > 0 {
+				serialized, err := c.Serialize()
+				if err != nil {
+					return err
+				}
+				nodes[string(childPath)] = serialized
+			}
+		default:
+			continue
+		}
+		err := node.SetChild(i, verkle.HashedNode{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *NodeStore) Clear() error {
+	s.cache.Purge()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(nodeBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(nodeBucket)
+		return err
+	})
+}
+
+func (s *NodeStore) Close() error {
+	return s.db.Close()
+}
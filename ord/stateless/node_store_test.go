@@ -0,0 +1,166 @@
+package stateless
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+	"github.com/holiman/uint256"
+)
+
+// checkNodeStore compares the stored node at each path of the resident tree with the resident one.
+// The commitments are compared since the uncompressed serialization of the same point may differ.
+func checkNodeStore(t *testing.T, store *NodeStore, node *verkle.InternalNode, path []byte) {
+	for i, child := range node.Children() {
+		childPath := append(path[:len(path):len(path)], byte(i))
+		switch c := child.(type) {
+		case *verkle.InternalNode, *verkle.LeafNode:
+			serialized, err := store.Resolve(childPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := verkle.ParseNode(serialized, byte(len(childPath)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Commitment().Bytes() != c.Commit().Bytes() {
+				t.Fatalf("the stored node at path %x is stale", childPath)
+			}
+			if internal, ok := c.(*verkle.InternalNode); ok {
+				checkNodeStore(t, store, internal, childPath)
+			}
+		}
+	}
+}
+
+func TestNodeStore(t *testing.T) {
+	store, err := NewNodeStore(filepath.Join(t.TempDir(), "nodes.db"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	resident := verkle.New()
+	flushed := verkle.New()
+
+	var keys [][]byte
+	for block := range 4 {
+		var written [][verkle.KeySize]byte
+		for i := range 64 {
+			key := GetTickHash("ordi", byte(i))
+			key[0] = byte(block*64 + i)
+			if block == 3 {
+				// Share the prefix with the keys of the first block, so the stored leaves are split and moved.
+				key = append([]byte{}, keys[i]...)
+				key[2] ^= 0xff
+			}
+			value := GetTickHash("ordi", byte(block))
+			keys = append(keys, key)
+			written = append(written, [verkle.KeySize]byte(key))
+			if err := resident.Insert(key, value, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := flushed.Insert(key, value, store.Resolve); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Update the keys of the previous block, their nodes are resolved from the store.
+		if block > 0 {
+			for _, key := range keys[(block-1)*64 : block*64-32] {
+				value := GetTickHash("sats", byte(block))
+				written = append(written, [verkle.KeySize]byte(key))
+				if err := resident.Insert(key, value, nil); err != nil {
+					t.Fatal(err)
+				}
+				if err := flushed.Insert(key, value, store.Resolve); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		// The reads resolve the unchanged nodes, they are not written again.
+		if _, err := flushed.Get(keys[0], store.Resolve); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Flush(flushed, written); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, key := range keys {
+		expected, err := resident.Get(key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := flushed.Get(key, store.Resolve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, value) {
+			t.Fatalf("mismatched value at key %x", key)
+		}
+	}
+
+	if resident.Commit().Bytes() != flushed.Commit().Bytes() {
+		t.Fatal("mismatched commitment between the resident and the flushed tree")
+	}
+	checkNodeStore(t, store, resident.(*verkle.InternalNode), nil)
+
+	// Nothing is written without the changed keys.
+	if err := store.Flush(flushed, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, _, err := verkle.MakeVerkleMultiProof(flushed, nil, keys[:8], store.Resolve); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHeaderAttach(t *testing.T) {
+	store, err := NewNodeStore(filepath.Join(t.TempDir(), "nodes.db"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	UseNodeStore(store)
+	defer func() {
+		nodeStore = nil
+		NodeResolveFn = nil
+	}()
+
+	served := newTestHeader()
+	rebuilt := newTestHeader()
+	rebuilt.detached = true
+	for i := range 64 {
+		key := GetTickHash("ordi", byte(i))
+		key[0] = byte(i)
+		served.InsertUInt256(key, uint256.NewInt(1))
+		rebuilt.InsertUInt256(key, uint256.NewInt(2))
+	}
+	if err := served.PagingWithHash("hash1", NodeResolveFn); err != nil {
+		t.Fatal(err)
+	}
+	// The detached tree is rebuilt while the served one is read, the stored nodes stay intact.
+	if err := rebuilt.PagingWithHash("fork1", NodeResolveFn); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 64 {
+		key := GetTickHash("ordi", byte(i))
+		key[0] = byte(i)
+		value, err := served.Root.Get(key, NodeResolveFn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if new(uint256.Int).SetBytes(value).Uint64() != 1 {
+			t.Fatal("unexpected value of the served tree", value)
+		}
+	}
+
+	expected := rebuilt.Root.Copy()
+	if err := rebuilt.Attach(); err != nil {
+		t.Fatal(err)
+	}
+	checkNodeStore(t, store, expected.(*verkle.InternalNode), nil)
+	if expected.Commit().Bytes() != rebuilt.Root.Commit().Bytes() {
+		t.Fatal("mismatched commitment after the attach")
+	}
+}
@@ -6,8 +6,9 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"time"
 
-	"github.com/RiemaLabs/modular-indexer-committee/ord"
+	"github.com/RiemaLabs/modular-indexer-committee/internal/metrics"
 	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
 	goipa "github.com/crate-crypto/go-ipa"
 	"github.com/crate-crypto/go-ipa/common"
@@ -28,10 +29,11 @@ func (state DiffState) Copy() DiffState {
 
 	newDiff := AccessList{Elements: newElements}
 	return DiffState{
-		Height:       state.Height,
-		Hash:         state.Hash,
-		Access:       newDiff,
-		VerkleCommit: state.VerkleCommit,
+		Height:         state.Height,
+		Hash:           state.Hash,
+		Access:         newDiff,
+		VerkleCommit:   state.VerkleCommit,
+		OrdTransDigest: state.OrdTransDigest,
 	}
 }
 
@@ -43,6 +45,23 @@ func (queue *Queue) LatestHeight() uint {
 	return queue.Header.Height
 }
 
+// Depth returns the number of blocks kept in the history.
+func (queue *Queue) Depth() uint {
+	return uint(len(queue.History))
+}
+
+// IsDeepReorg reports whether the reorg at reorgHeight is beyond the history, so Recovery can't handle it.
+func (queue *Queue) IsDeepReorg(reorgHeight uint) bool {
+	return reorgHeight <= queue.StartHeight()
+}
+
+// Replace takes over the header and the history of the other queue, e.g. rebuilt after a deep reorg.
+func (queue *Queue) Replace(other *Queue) {
+	queue.Header = other.Header
+	queue.History = other.History
+	queue.LastStateProof = other.LastStateProof
+}
+
 func (queue *Queue) Println() {
 	log.Println("====", queue.Header.Height, "====", queue.Header.Hash, "====")
 	for _, node := range queue.History {
@@ -50,26 +69,29 @@ func (queue *Queue) Println() {
 	}
 }
 
-func (queue *Queue) Update(getter getter.OrdGetter, latestHeight uint) error {
+// Update executes the blocks up to latestHeight, all of them are read from one consistent view of the getter.
+func (queue *Queue) Update(ordGetter getter.OrdGetter, latestHeight uint) error {
 	queue.Lock()
 	defer queue.Unlock()
 	curHeight := queue.Header.Height
+	if curHeight >= latestHeight {
+		return nil
+	}
+	// Query before the execution, so a failed query leaves the queue untouched.
+	view, err := getter.ReadBlockView(ordGetter, curHeight+1, latestHeight)
+	if err != nil {
+		return err
+	}
 	for i := curHeight + 1; i <= latestHeight; i++ {
-		ordTransfer, err := getter.GetOrdTransfers(i)
-		if err != nil {
-			return err
-		}
+		ordTransfer := view.OrdTransfers(i)
 		// Write to Diff
 		Exec(queue.Header, ordTransfer, i)
-		hash, err := getter.GetBlockHash(i - 1)
-		if err != nil {
-			return err
-		}
 		newDiffState := DiffState{
-			Height:       i - 1,
-			Hash:         hash,
-			Access:       queue.Header.Access,
-			VerkleCommit: queue.Header.Root.Commit().Bytes(),
+			Height:         i - 1,
+			Hash:           view.BlockHash(i - 1),
+			Access:         queue.Header.Access,
+			VerkleCommit:   queue.Header.Root.Commit().Bytes(),
+			OrdTransDigest: getter.DigestOrdTransfers(queue.Header.OrdTrans),
 		}
 		copy(queue.History[:], queue.History[1:])
 		queue.History[len(queue.History)-1] = newDiffState
@@ -83,78 +105,149 @@ func (queue *Queue) Update(getter getter.OrdGetter, latestHeight uint) error {
 		}
 
 		queue.Header.OrdTrans = ordTransfer
-		_ = queue.Header.Paging(getter, true, NodeResolveFn)
+		err = queue.Header.PagingWithHash(view.BlockHash(i), NodeResolveFn)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 func Rollingback(header *Header, stateDiff *DiffState) (verkle.VerkleNode, [][]byte) {
 	var keys [][]byte
-	kvMap := make(KeyValueMap)
-	for k, v := range header.KV {
-		kvMap[k] = v
-	}
-
+	touched := make(map[[verkle.KeySize]byte]struct{})
 	for _, elem := range stateDiff.Access.Elements {
 		keys = append(keys, elem.Key[:])
-		if elem.OldValueExists {
-			kvMap[elem.Key] = elem.OldValue
-		} else {
-			delete(kvMap, elem.Key)
-		}
+		touched[elem.Key] = struct{}{}
 	}
 
-	rollback := verkle.New()
-	for k, v := range kvMap {
-		_ = rollback.Insert(k[:], v[:], NodeResolveFn)
+	started := time.Now()
+	rollback, err := BuildTree(func(fn func(k [verkle.KeySize]byte, v [ValueSize]byte) error) error {
+		err := header.KV.Iterate(func(k [verkle.KeySize]byte, v [ValueSize]byte) error {
+			if _, found := touched[k]; found {
+				return nil
+			}
+			return fn(k, v)
+		})
+		if err != nil {
+			return err
+		}
+		for _, elem := range stateDiff.Access.Elements {
+			if elem.OldValueExists {
+				if err := fn(elem.Key, elem.OldValue); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, NodeResolveFn)
+	if err != nil {
+		panic(err)
 	}
-	// The call of Commit is necessary to refresh the root commit.
-	rollback.Commit()
+	metrics.ObserveTreeBuild("rollback", started)
 
 	return rollback, keys
 }
 
-func (queue *Queue) Recovery(getter getter.OrdGetter, reorgHeight uint) error {
+// Recovery rolls the queue back below reorgHeight and executes the blocks of the new chain up to the current height.
+// The failures before the rollback leave the queue untouched, the ones after it panic, see rollbackTo.
+func (queue *Queue) Recovery(ordGetter getter.OrdGetter, reorgHeight uint) error {
 	queue.Lock()
 	defer queue.Unlock()
 	curHeight := queue.Header.Height
 	startHeight := queue.StartHeight()
+	if queue.IsDeepReorg(reorgHeight) {
+		return fmt.Errorf("the reorg at height %d is beyond the history starting at height %d", reorgHeight, startHeight)
+	}
+
+	// Query the new chain before the rollback, so a failed query leaves the queue untouched.
+	view, err := getter.ReadBlockView(ordGetter, reorgHeight, curHeight)
+	if err != nil {
+		return err
+	}
 
 	// Rollback to the reorgHeight - 1.
-	for i := curHeight - 1; i >= reorgHeight-1; i-- {
+	queue.rollbackTo(reorgHeight - 1)
+
+	// Compute to the curHeight from the reorgHeight.
+	for i := reorgHeight; i <= curHeight; i++ {
+		index := i - startHeight - 1
+		// The transfers of the header are stale after the rollback, the block below the reorg is unchanged.
+		ordTransDigest := queue.History[index].OrdTransDigest
+		if i > reorgHeight {
+			ordTransDigest = getter.DigestOrdTransfers(queue.Header.OrdTrans)
+		}
+		ordTransfer := view.OrdTransfers(i)
+		Exec(queue.Header, ordTransfer, i)
+		queue.History[index] = DiffState{
+			Height:         i - 1,
+			Hash:           view.BlockHash(i - 1),
+			Access:         queue.Header.Access,
+			VerkleCommit:   queue.Header.Root.Commit().Bytes(),
+			OrdTransDigest: ordTransDigest,
+		}
+		queue.Header.OrdTrans = ordTransfer
+		err = queue.Header.PagingWithHash(view.BlockHash(i), NodeResolveFn)
+		if err != nil {
+			panic(fmt.Errorf("failed to execute the block %d of the new chain after the rollback: %w", i, err))
+		}
+	}
+
+	return nil
+}
+
+// RollbackTo rolls the header back in place to height within the history, e.g. to persist a confirmed state on shutdown.
+// The history above height is stale afterwards, so the queue must not be updated again.
+func (queue *Queue) RollbackTo(height uint) error {
+	queue.Lock()
+	defer queue.Unlock()
+	if height < queue.StartHeight() || height > queue.LatestHeight() {
+		return fmt.Errorf("the height %d is out of the history from %d to %d", height, queue.StartHeight(), queue.LatestHeight())
+	}
+	queue.rollbackTo(height)
+	return nil
+}
+
+// rollbackTo restores the old values of the history block by block, the caller must hold the lock.
+// The store and the tree are changed in place, so a failure or a mismatched commitment panics rather than leaving
+// the queue half rolled back to be served. The state is rebuilt from the snapshots and the journal on the restart.
+func (queue *Queue) rollbackTo(height uint) {
+	startHeight := queue.StartHeight()
+	for queue.Header.Height > height {
+		i := queue.Header.Height - 1
 		index := i - startHeight
 		pastState := queue.History[index]
 
-		// Inner bug in go-verkle, doesn't work.
-		// for _, elem := range pastState.Diff.Elements {
-		// 	if elem.OldValueExists {
-		// 		queue.Header.KV[elem.Key] = elem.OldValue
-		// 		queue.Header.Root.Insert(elem.Key[:], elem.OldValue[:], NodeResolveFn)
-		// 	} else {
-		// 		delete(queue.Header.KV, elem.Key)
-		// 		queue.Header.Root.Delete(elem.Key[:], NodeResolveFn)
-		// 	}
-		// }
-		// newRoot := queue.Header.Root
-		// newBytes := queue.Header.Root.Commit().Bytes()
-		// n := base64.StdEncoding.EncodeToString(newBytes[:])
-
+		puts := make(KeyValueMap)
+		var deletes [][verkle.KeySize]byte
 		for _, elem := range pastState.Access.Elements {
-			if elem.OldValueExists {
-				queue.Header.KV[elem.Key] = elem.OldValue
-			} else {
-				delete(queue.Header.KV, elem.Key)
+			if !elem.OldValueExists {
+				deletes = append(deletes, elem.Key)
+			} else if elem.OldValue != elem.NewValue {
+				puts[elem.Key] = elem.OldValue
 			}
 		}
-		newRoot := verkle.New()
-		for k, v := range queue.Header.KV {
-			_ = newRoot.Insert(k[:], v[:], NodeResolveFn)
+		err := queue.Header.KV.Apply(puts, deletes)
+		if err != nil {
+			panic(fmt.Errorf("failed to roll back the store to the block %d: %w", i, err))
+		}
+		// The tree is rolled back in place, the deletion is fixed up by RollbackTree for the bug of go-verkle.
+		started := time.Now()
+		newRoot := queue.Header.Root
+		err = RollbackTree(newRoot, puts, deletes, NodeResolveFn)
+		if err != nil {
+			panic(fmt.Errorf("failed to roll back the tree to the block %d: %w", i, err))
+		}
+		metrics.ObserveTreeBuild("recovery", started)
+		err = queue.Header.flushNodes(append(writtenKeys(puts), deletes...))
+		if err != nil {
+			panic(fmt.Errorf("failed to flush the tree rolled back to the block %d: %w", i, err))
 		}
 		newBytes := newRoot.Commit().Bytes()
 		n := base64.StdEncoding.EncodeToString(newBytes[:])
 		o := base64.StdEncoding.EncodeToString(pastState.VerkleCommit[:])
 		if n != o {
-			panic(fmt.Sprintf("Recovery the header failed! The commitment is different: %s and %s", n, o))
+			panic(fmt.Errorf("recovery the header failed, the commitment is different: %s and %s", n, o))
 		}
 		newHeader := Header{
 			Root:           newRoot,
@@ -164,36 +257,14 @@ func (queue *Queue) Recovery(getter getter.OrdGetter, reorgHeight uint) error {
 			Access:         AccessList{},
 			IntermediateKV: KeyValueMap{},
 			OrdTrans:       queue.Header.OrdTrans,
+			detached:       queue.Header.detached,
 		}
 		queue.Header = &newHeader
 	}
-
-	// Compute to the curHeight from the reorgHeight.
-	for i := reorgHeight; i <= curHeight; i++ {
-		index := i - startHeight - 1
-		ordTransfer, err := getter.GetOrdTransfers(i)
-		if err != nil {
-			return err
-		}
-		Exec(queue.Header, ordTransfer, i)
-		var hash string
-		hash, err = getter.GetBlockHash(i - 1)
-		if err != nil {
-			return err
-		}
-		queue.History[index] = DiffState{
-			Height:       i - 1,
-			Hash:         hash,
-			Access:       queue.Header.Access,
-			VerkleCommit: queue.Header.Root.Commit().Bytes(),
-		}
-		queue.Header.OrdTrans = ordTransfer
-		_ = queue.Header.Paging(getter, true, NodeResolveFn)
-	}
-
-	return nil
 }
 
+// CheckForReorg returns the lowest height in the history whose block hash has changed, or 0 if there is no reorg.
+// A change at the start of the history means the fork is older than the history, see IsDeepReorg.
 func (queue *Queue) CheckForReorg(getter getter.OrdGetter) (uint, error) {
 	queue.Lock()
 	defer queue.Unlock()
@@ -215,32 +286,40 @@ func (queue *Queue) CheckForReorg(getter getter.OrdGetter) (uint, error) {
 	return 0, nil
 }
 
-func NewQueues(getter getter.OrdGetter, header *Header, queryHash bool, startHeight uint) (*Queue, error) {
-	var stateList [ord.BitcoinConfirmations]DiffState
+// NewQueues executes depth blocks from startHeight on the header and keeps their differences in the history.
+// The blocks are read from one consistent view of the getter, the header must hold the transfers of its block.
+func NewQueues(ordGetter getter.OrdGetter, header *Header, queryHash bool, startHeight uint, depth uint) (*Queue, error) {
+	if depth == 0 {
+		return nil, fmt.Errorf("the depth of the queue must be positive")
+	}
+	view, err := getter.ReadBlockView(ordGetter, startHeight, startHeight+depth-1)
+	if err != nil {
+		return nil, err
+	}
+	stateList := make([]DiffState, depth)
 	var proof *verkle.Proof
-	for i := startHeight; i <= startHeight+ord.BitcoinConfirmations-1; i++ {
-		ordTransfer, err := getter.GetOrdTransfers(i)
-		if err != nil {
-			return nil, err
-		}
+	for i := startHeight; i <= startHeight+depth-1; i++ {
+		ordTransfer := view.OrdTransfers(i)
 		Exec(header, ordTransfer, i)
 		var hash string
 		if queryHash {
-			hash, err = getter.GetBlockHash(i - 1)
-			if err != nil {
-				return nil, err
-			}
+			hash = view.BlockHash(i - 1)
 		}
 		stateList[i-startHeight] = DiffState{
-			Height:       i - 1,
-			Hash:         hash,
-			Access:       header.Access,
-			VerkleCommit: header.Root.Commit().Bytes(),
+			Height:         i - 1,
+			Hash:           hash,
+			Access:         header.Access,
+			VerkleCommit:   header.Root.Commit().Bytes(),
+			OrdTransDigest: getter.DigestOrdTransfers(header.OrdTrans),
 		}
-		if i == startHeight+ord.BitcoinConfirmations-1 {
+		if i == startHeight+depth-1 {
 			proof, _ = generateProofFromUpdate(header, &stateList[i-startHeight])
 		}
-		_ = header.Paging(getter, true, NodeResolveFn)
+		header.OrdTrans = ordTransfer
+		err = header.PagingWithHash(view.BlockHash(i), NodeResolveFn)
+		if err != nil {
+			return nil, err
+		}
 	}
 	// The call of Commit is necessary to refresh the root commit.
 	header.Root.Commit()
@@ -0,0 +1,51 @@
+package stateless
+
+import (
+	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
+)
+
+// FindForkHeight returns the highest height not above height whose block hash is still on the chain of the getter.
+// The hashes recorded in the journal are checked first, then the ones of the snapshots, which only give a lower bound.
+// It returns BRC20StartHeight - 1 if no recorded hash is on the chain.
+func FindForkHeight(ordGetter getter.OrdGetter, snapshots *SnapshotManager, height uint) (uint, error) {
+	if journal != nil {
+		for h := height; h >= BRC20StartHeight; h-- {
+			entry, found, err := journal.Get(h)
+			if err != nil {
+				return 0, err
+			}
+			if !found {
+				break
+			}
+			hash, err := ordGetter.GetBlockHash(h)
+			if err != nil {
+				return 0, err
+			}
+			if hash == entry.Hash {
+				return h, nil
+			}
+		}
+	}
+
+	heights, err := snapshots.Heights()
+	if err != nil {
+		return 0, err
+	}
+	for _, h := range heights {
+		if h > height {
+			continue
+		}
+		sh, err := ReadSnapshotHeader(snapshots.Path(h))
+		if err != nil {
+			continue
+		}
+		hash, err := ordGetter.GetBlockHash(h)
+		if err != nil {
+			return 0, err
+		}
+		if hash == sh.Hash {
+			return h, nil
+		}
+	}
+	return BRC20StartHeight - 1, nil
+}
@@ -0,0 +1,149 @@
+package stateless
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
+	"github.com/ethereum/go-verkle"
+	"github.com/holiman/uint256"
+)
+
+func TestFindForkHeight(t *testing.T) {
+	dir := t.TempDir()
+	j, err := NewJournal(filepath.Join(dir, "journal.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	UseJournal(j)
+	defer UseJournal(nil)
+
+	snapshots := NewSnapshotManager(dir, 4, RetentionPolicy{})
+	header := newTestHeader()
+	header.Height = BRC20StartHeight - 1
+	for i := range 10 {
+		header.InsertUInt256(GetTickHash("ordi", byte(i)), uint256.NewInt(1))
+		if err := header.Paging(journalGetter{}, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		if snapshots.Due(header.Height) {
+			if err := snapshots.StoreHeader(journalGetter{}, header); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tip := header.Height
+	fork := BRC20StartHeight + 6
+	snapshotBelowFork := fork - fork%snapshots.Interval
+
+	cases := []struct {
+		name     string
+		journal  *Journal
+		getter   journalGetter
+		expected uint
+	}{
+		{"no fork", j, journalGetter{}, tip},
+		{"journal", j, journalGetter{forkHeight: fork}, fork},
+		{"snapshots", nil, journalGetter{forkHeight: fork}, snapshotBelowFork},
+		{"nothing", nil, journalGetter{forkHeight: BRC20StartHeight - 1}, BRC20StartHeight - 1},
+	}
+	for _, c := range cases {
+		UseJournal(c.journal)
+		forkHeight, err := FindForkHeight(c.getter, snapshots, tip)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if forkHeight != c.expected {
+			t.Fatalf("%s: unexpected fork height %d, expected %d", c.name, forkHeight, c.expected)
+		}
+	}
+}
+
+func TestQueueDepth(t *testing.T) {
+	header := &Header{Root: verkle.New(), KV: NewMemoryStore(), Access: AccessList{}, IntermediateKV: KeyValueMap{}}
+	queue, err := NewQueues(journalGetter{}, header, true, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queue.Depth() != 10 || queue.StartHeight() != 0 || queue.LatestHeight() != 10 {
+		t.Fatal("unexpected queue", queue.Depth(), queue.StartHeight(), queue.LatestHeight())
+	}
+
+	reorgHeight, err := queue.CheckForReorg(journalGetter{forkHeight: 5})
+	if err != nil || reorgHeight != 6 || queue.IsDeepReorg(reorgHeight) {
+		t.Fatal("unexpected shallow reorg", reorgHeight, err)
+	}
+	reorgHeight, err = queue.CheckForReorg(journalGetter{forkHeight: 1})
+	if err != nil || reorgHeight != 2 || queue.IsDeepReorg(reorgHeight) {
+		t.Fatal("unexpected shallow reorg", reorgHeight, err)
+	}
+	// journalGetter doesn't fork at 0, so a fork below the queue is simulated by changing the recorded hash.
+	queue.History[0].Hash = "stale"
+	reorgHeight, err = queue.CheckForReorg(journalGetter{})
+	if err != nil || !queue.IsDeepReorg(reorgHeight) {
+		t.Fatal("the deep reorg is not detected", reorgHeight, err)
+	}
+	if err := queue.Recovery(journalGetter{}, reorgHeight); err == nil {
+		t.Fatal("the deep reorg is recovered from the history")
+	}
+}
+
+// transferGetter moves an inscription named after the block hash in each block, so the transfers change on a fork.
+type transferGetter struct {
+	journalGetter
+}
+
+func (g transferGetter) GetOrdTransfers(blockHeight uint) ([]getter.OrdTransfer, error) {
+	hash, _ := g.GetBlockHash(blockHeight)
+	return []getter.OrdTransfer{{InscriptionID: hash + "i0", BlockHeight: blockHeight}}, nil
+}
+
+func (g transferGetter) GetOrdTransfersInRange(fromHeight uint, toHeight uint) ([][]getter.OrdTransfer, error) {
+	var blocks [][]getter.OrdTransfer
+	for i := fromHeight; i <= toHeight; i++ {
+		ots, _ := g.GetOrdTransfers(i)
+		blocks = append(blocks, ots)
+	}
+	return blocks, nil
+}
+
+func TestQueueOrdTransDigest(t *testing.T) {
+	check := func(name string, g transferGetter, queue *Queue) {
+		t.Helper()
+		for _, state := range queue.History {
+			ots, _ := g.GetOrdTransfers(state.Height)
+			if state.OrdTransDigest != getter.DigestOrdTransfers(ots) {
+				t.Fatalf("%s: unexpected digest of the transfers at height %d", name, state.Height)
+			}
+		}
+		ots, _ := g.GetOrdTransfers(queue.LatestHeight())
+		if len(queue.Header.OrdTrans) != 1 || queue.Header.OrdTrans[0].InscriptionID != ots[0].InscriptionID {
+			t.Fatalf("%s: unexpected transfers of the header: %v", name, queue.Header.OrdTrans)
+		}
+	}
+
+	g := transferGetter{}
+	header := &Header{Root: verkle.New(), KV: NewMemoryStore(), Access: AccessList{}, IntermediateKV: KeyValueMap{}}
+	header.OrdTrans, _ = g.GetOrdTransfers(0)
+	queue, err := NewQueues(g, header, true, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("new", g, queue)
+
+	forked := transferGetter{journalGetter{forkHeight: 5}}
+	reorgHeight, err := queue.CheckForReorg(forked)
+	if err != nil || reorgHeight != 6 {
+		t.Fatal("unexpected reorg", reorgHeight, err)
+	}
+	if err := queue.Recovery(forked, reorgHeight); err != nil {
+		t.Fatal(err)
+	}
+	check("recovered", forked, queue)
+
+	if err := queue.Update(forked, 12); err != nil {
+		t.Fatal(err)
+	}
+	check("updated", forked, queue)
+}
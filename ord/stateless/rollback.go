@@ -0,0 +1,167 @@
+package stateless
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-verkle"
+)
+
+// RollbackTree applies the puts and the deletes to the tree in place, the cost is proportional to the size of the diff.
+// The tree is left in the same shape as a tree built from scratch with the resulting key values.
+func RollbackTree(root verkle.VerkleNode, puts KeyValueMap, deletes [][verkle.KeySize]byte, nodeResolverFn verkle.NodeResolverFn) error {
+	internal, ok := root.(*verkle.InternalNode)
+	if !ok {
+		return fmt.Errorf("the root of the verkle tree must be an internal node")
+	}
+	// The deletion updates the commitments of the touched nodes based on the committed ones.
+	internal.Commit()
+	for _, key := range deletes {
+		err := deleteKey(internal, key, nodeResolverFn)
+		if err != nil {
+			return err
+		}
+	}
+	for key, value := range puts {
+		err := internal.Insert(key[:], value[:], nodeResolverFn)
+		if err != nil {
+			return err
+		}
+	}
+	// The call of Commit is necessary to refresh the root commit.
+	internal.Commit()
+	return nil
+}
+
+// deleteKey works around the deletion of go-verkle, which leaves an inner node with a single leaf in place
+// while the tree built by insertion keeps the leaf at the parent of that inner node.
+// After the deletion, such inner nodes on the path of the key are collapsed from the bottom up.
+func deleteKey(root *verkle.InternalNode, key [verkle.KeySize]byte, nodeResolverFn verkle.NodeResolverFn) error {
+	value, err := root.Get(key[:], nodeResolverFn)
+	if err != nil {
+		return err
+	}
+	if len(value) == 0 {
+		return nil
+	}
+	_, err = root.Delete(key[:], nodeResolverFn)
+	if err != nil {
+		return err
+	}
+
+	// The inner nodes along the stem, path[i] is at depth i.
+	path := []*verkle.InternalNode{root}
+	for depth := 0; depth < verkle.StemSize; depth++ {
+		child, err := resolveChild(path[depth], key[:depth], key[depth], nodeResolverFn)
+		if err != nil {
+			return err
+		}
+		if leaf, ok := child.(*verkle.LeafNode); ok {
+			err := rebuildLeaf(path[depth], key[depth], byte(depth+1), leaf)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		next, ok := child.(*verkle.InternalNode)
+		if !ok {
+			break
+		}
+		path = append(path, next)
+	}
+
+	for depth := len(path) - 1; depth > 0; depth-- {
+		leaf, err := singleLeaf(path[depth], key[:depth], nodeResolverFn)
+		if err != nil {
+			return err
+		}
+		if leaf == nil {
+			// The shapes of the nodes above are not affected.
+			return nil
+		}
+		// Move the leaf up to replace the inner node. The parent has recorded the commitment of the inner node
+		// before the deletion, so the commitment of the parent is updated by the next Commit.
+		leaf.Commit()
+		serialized, err := leaf.Serialize()
+		if err != nil {
+			return err
+		}
+		moved, err := verkle.ParseNode(serialized, byte(depth))
+		if err != nil {
+			return err
+		}
+		err = path[depth-1].SetChild(int(key[depth-1]), moved)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildLeaf replaces the leaf left by the deletion with a leaf built from its values. The deletion of the last
+// value of a half of the leaf drops the commitment of that half, the leaf can be neither serialized nor inserted into.
+func rebuildLeaf(parent *verkle.InternalNode, index byte, depth byte, leaf *verkle.LeafNode) error {
+	rebuilt, err := verkle.NewLeafNode(leaf.Key(0)[:verkle.StemSize], leaf.Values())
+	if err != nil {
+		return err
+	}
+	serialized, err := rebuilt.Serialize()
+	if err != nil {
+		return err
+	}
+	// The parsed leaf carries its depth, which NewLeafNode leaves at 0.
+	parsed, err := verkle.ParseNode(serialized, depth)
+	if err != nil {
+		return err
+	}
+	return parent.SetChild(int(index), parsed)
+}
+
+// resolveChild returns the child at index of the inner node at prefix, the hashed child is resolved in place.
+func resolveChild(node *verkle.InternalNode, prefix []byte, index byte, nodeResolverFn verkle.NodeResolverFn) (verkle.VerkleNode, error) {
+	child := node.Children()[index]
+	if _, ok := child.(verkle.HashedNode); !ok {
+		return child, nil
+	}
+	if nodeResolverFn == nil {
+		return nil, fmt.Errorf("the hashed node at path %x can't be resolved", append(prefix, index))
+	}
+	childPath := make([]byte, 0, len(prefix)+1)
+	childPath = append(append(childPath, prefix...), index)
+	serialized, err := nodeResolverFn(childPath)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := verkle.ParseNode(serialized, byte(len(childPath)))
+	if err != nil {
+		return nil, err
+	}
+	// Resolving doesn't change the commitment, the parent needs no update.
+	err = node.SetChild(int(index), resolved)
+	if err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// singleLeaf returns the only child of the inner node at prefix if it is a leaf, otherwise nil.
+func singleLeaf(node *verkle.InternalNode, prefix []byte, nodeResolverFn verkle.NodeResolverFn) (*verkle.LeafNode, error) {
+	var leaf *verkle.LeafNode
+	for i, child := range node.Children() {
+		if _, ok := child.(verkle.Empty); ok {
+			continue
+		}
+		if leaf != nil {
+			return nil, nil
+		}
+		resolved, err := resolveChild(node, prefix, byte(i), nodeResolverFn)
+		if err != nil {
+			return nil, err
+		}
+		l, ok := resolved.(*verkle.LeafNode)
+		if !ok {
+			return nil, nil
+		}
+		leaf = l
+	}
+	return leaf, nil
+}
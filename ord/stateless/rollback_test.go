@@ -0,0 +1,216 @@
+package stateless
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+	"github.com/holiman/uint256"
+)
+
+func TestRollbackTree(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomKey := func(prefix int) [verkle.KeySize]byte {
+		var key [verkle.KeySize]byte
+		r.Read(key[:])
+		// Cluster the keys under a few prefixes, so the deletion leaves inner nodes to collapse.
+		for i := range prefix {
+			key[i] = byte(i)
+		}
+		return key
+	}
+	randomValue := func() [ValueSize]byte {
+		var value [ValueSize]byte
+		r.Read(value[:])
+		return value
+	}
+
+	for _, resolver := range []string{"resident", "flushed"} {
+		t.Run(resolver, func(t *testing.T) {
+			var nodeResolverFn verkle.NodeResolverFn
+			var store *NodeStore
+			if resolver == "flushed" {
+				var err error
+				store, err = NewNodeStore(filepath.Join(t.TempDir(), "nodes.db"), 16)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer store.Close()
+				nodeResolverFn = store.Resolve
+			}
+
+			before := make(KeyValueMap)
+			for i := range 500 {
+				before[randomKey(i%4)] = randomValue()
+			}
+			root := verkle.New()
+			for key, value := range before {
+				if err := root.Insert(key[:], value[:], nodeResolverFn); err != nil {
+					t.Fatal(err)
+				}
+			}
+			root.Commit()
+			if store != nil {
+				if err := store.FlushAll(root); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The block updates some keys, creates new keys next to the old ones and on new stems.
+			puts := make(KeyValueMap)
+			var deletes [][verkle.KeySize]byte
+			for key := range before {
+				if r.Intn(4) == 0 {
+					puts[key] = before[key]
+					value := randomValue()
+					if err := root.Insert(key[:], value[:], nodeResolverFn); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if r.Intn(8) == 0 {
+					sibling := key
+					sibling[verkle.StemSize]++
+					if _, found := before[sibling]; !found {
+						deletes = append(deletes, sibling)
+						value := randomValue()
+						if err := root.Insert(sibling[:], value[:], nodeResolverFn); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+			}
+			for i := range 200 {
+				key := randomKey(i%5 + 1)
+				deletes = append(deletes, key)
+				value := randomValue()
+				if err := root.Insert(key[:], value[:], nodeResolverFn); err != nil {
+					t.Fatal(err)
+				}
+			}
+			changed := append(writtenKeys(puts), deletes...)
+			root.Commit()
+			if store != nil {
+				if err := store.Flush(root, changed); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := RollbackTree(root, puts, deletes, nodeResolverFn); err != nil {
+				t.Fatal(err)
+			}
+			if store != nil {
+				if err := store.Flush(root, changed); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := BuildTree(func(fn func(key [verkle.KeySize]byte, value [ValueSize]byte) error) error {
+				for key, value := range before {
+					if err := fn(key, value); err != nil {
+						return err
+					}
+				}
+				return nil
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root.Commit().Bytes() != expected.Commit().Bytes() {
+				t.Fatal("mismatched commitment between the rolled back tree and the rebuilt tree")
+			}
+			if store != nil {
+				checkNodeStore(t, store, expected.(*verkle.InternalNode), nil)
+			}
+		})
+	}
+}
+
+// newTestQueue pages 6 blocks, each overwrites a key of the previous block and creates a new one.
+func TestRollbackTreeHalfLeaf(t *testing.T) {
+	root := verkle.New()
+	low := GetTickHash("ordi", 0)
+	high := GetTickHash("ordi", 200)
+	value := uint256.NewInt(1).Bytes32()
+	for _, key := range [][]byte{low, high} {
+		if err := root.Insert(key, value[:], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Delete the only value of the upper half of the leaf.
+	if err := RollbackTree(root, nil, [][verkle.KeySize]byte{[verkle.KeySize]byte(high)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.(*verkle.InternalNode).Children()[low[0]].Serialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Insert(high, value[:], nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := verkle.New()
+	for _, key := range [][]byte{low, high} {
+		if err := expected.Insert(key, value[:], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if root.Commit().Bytes() != expected.Commit().Bytes() {
+		t.Fatal("mismatched commitment after inserting into the emptied half")
+	}
+}
+
+func newTestQueue(t *testing.T) *Queue {
+	header := newTestHeader()
+	queue := &Queue{Header: header}
+	for block := uint(1); block <= 6; block++ {
+		// Overwrite a key of the previous block and create a new one.
+		for i := range 2 {
+			key := GetTickHash("ordi", byte(block+uint(i)))
+			header.InsertUInt256(key, uint256.NewInt(uint64(block)))
+		}
+		hash, _ := journalGetter{}.GetBlockHash(header.Height)
+		queue.History = append(queue.History, DiffState{
+			Height:       header.Height,
+			Hash:         hash,
+			Access:       header.Access,
+			VerkleCommit: header.Root.Commit().Bytes(),
+		})
+		if err := header.Paging(journalGetter{}, false, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return queue
+}
+
+func TestQueueRollbackTo(t *testing.T) {
+	queue := newTestQueue(t)
+	if err := queue.RollbackTo(queue.StartHeight() - 1); err == nil {
+		t.Fatal("rolled back below the history")
+	}
+	if err := queue.RollbackTo(3); err != nil {
+		t.Fatal(err)
+	}
+	if queue.LatestHeight() != 3 || queue.Header.Hash != "hash3" || queue.Header.Root.Commit().Bytes() != queue.History[3].VerkleCommit {
+		t.Fatal("unexpected state after the rollback", queue.LatestHeight(), queue.Header.Hash)
+	}
+	if err := queue.RollbackTo(queue.StartHeight()); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := BuildTree(queue.Header.KV.Iterate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := verkle.New().Commit().Bytes()
+	if queue.LatestHeight() != 0 || rebuilt.Commit().Bytes() != empty || queue.Header.Root.Commit().Bytes() != empty {
+		t.Fatal("the state at the start of the history isn't empty")
+	}
+}
+
+func TestQueueRollbackToMismatch(t *testing.T) {
+	queue := newTestQueue(t)
+	queue.History[4].VerkleCommit = [32]byte{}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("the mismatched commitment is returned with the half rolled back queue")
+		}
+	}()
+	_ = queue.RollbackTo(3)
+}
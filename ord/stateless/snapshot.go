@@ -0,0 +1,213 @@
+package stateless
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The version of the snapshot format, bump it whenever the layout of the snapshot changes.
+const SnapshotVersion uint32 = 1
+
+// The meta-protocol of the state stored in snapshots.
+const SnapshotMetaProtocol = "brc-20"
+
+// Layout of a snapshot file:
+// | magic (8 bytes) | length of the header record (4 bytes) | header record (JSON) | padding | content |
+// The header block has a fixed size so it can be written after the content is streamed to the disk.
+const snapshotMagic = "MICSNAP\x00"
+const snapshotHeaderSize = 1024
+
+// SnapshotHeader is the header record of a snapshot file.
+type SnapshotHeader struct {
+	Version      uint32 `json:"version"`
+	MetaProtocol string `json:"metaProtocol"`
+	Height       uint   `json:"height"`
+	Hash         string `json:"hash"`
+	// Base64 of the commitment of the verkle tree root.
+	Commitment string `json:"commitment"`
+	KeyCount   uint64 `json:"keyCount"`
+	// Hex of the SHA-256 of the content.
+	Checksum string `json:"checksum"`
+}
+
+func (sh *SnapshotHeader) encode() ([]byte, error) {
+	record, err := json.Marshal(sh)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshotMagic)+4+len(record) > snapshotHeaderSize {
+		return nil, fmt.Errorf("the snapshot header record is too large: %d bytes", len(record))
+	}
+	block := make([]byte, snapshotHeaderSize)
+	copy(block, snapshotMagic)
+	binary.BigEndian.PutUint32(block[len(snapshotMagic):], uint32(len(record)))
+	copy(block[len(snapshotMagic)+4:], record)
+	return block, nil
+}
+
+func decodeSnapshotHeader(block []byte) (*SnapshotHeader, error) {
+	if string(block[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("not a snapshot file")
+	}
+	length := int(binary.BigEndian.Uint32(block[len(snapshotMagic):]))
+	start := len(snapshotMagic) + 4
+	if start+length > snapshotHeaderSize {
+		return nil, fmt.Errorf("invalid length of the snapshot header record: %d", length)
+	}
+	var sh SnapshotHeader
+	err := json.Unmarshal(block[start:start+length], &sh)
+	if err != nil {
+		return nil, err
+	}
+	return &sh, nil
+}
+
+// WriteSnapshot streams the state of the header at the block hash into a snapshot file.
+// The file is written to a temporary path first and renamed, so a crash never leaves a partial snapshot.
+func WriteSnapshot(path string, header *Header, hash string) (*SnapshotHeader, error) {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	// Reserve the header block, it is filled after the content is written.
+	_, err = file.Write(make([]byte, snapshotHeaderSize))
+	if err != nil {
+		return nil, err
+	}
+	hasher := sha256.New()
+	writer := bufio.NewWriter(file)
+	keyCount, err := header.Serialize(io.MultiWriter(writer, hasher))
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	commitment := header.Root.Commit().Bytes()
+	sh := SnapshotHeader{
+		Version:      SnapshotVersion,
+		MetaProtocol: SnapshotMetaProtocol,
+		Height:       header.Height,
+		Hash:         hash,
+		Commitment:   base64.StdEncoding.EncodeToString(commitment[:]),
+		KeyCount:     keyCount,
+		Checksum:     hex.EncodeToString(hasher.Sum(nil)),
+	}
+	block, err := sh.encode()
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.WriteAt(block, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = file.Sync()
+	if err != nil {
+		return nil, err
+	}
+	err = file.Close()
+	file = nil
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	return &sh, nil
+}
+
+// ReadSnapshotHeader reads the header record of the snapshot file without loading the content.
+func ReadSnapshotHeader(path string) (*SnapshotHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	block := make([]byte, snapshotHeaderSize)
+	_, err = io.ReadFull(file, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the snapshot header: %w", err)
+	}
+	return decodeSnapshotHeader(block)
+}
+
+// LoadSnapshot rebuilds the header from the snapshot file into the store.
+// The version, meta-protocol, checksum, key count and root commitment are all verified.
+func LoadSnapshot(path string, store StateStore) (*Header, *SnapshotHeader, error) {
+	return loadSnapshot(path, func(reader io.Reader, height uint) (*Header, uint64, error) {
+		return Deserialize(reader, height, store, nil)
+	})
+}
+
+// LoadSnapshotTree is LoadSnapshot without a store, the tree is built while the file is read and serves the key values,
+// see treeStore. The header is read only.
+func LoadSnapshotTree(path string) (*Header, *SnapshotHeader, error) {
+	return loadSnapshot(path, DeserializeTree)
+}
+
+func loadSnapshot(path string, deserialize func(reader io.Reader, height uint) (*Header, uint64, error)) (*Header, *SnapshotHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	block := make([]byte, snapshotHeaderSize)
+	_, err = io.ReadFull(file, block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the snapshot header: %w", err)
+	}
+	sh, err := decodeSnapshotHeader(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sh.Version != SnapshotVersion {
+		return nil, sh, fmt.Errorf("unsupported snapshot version: %d, expected: %d", sh.Version, SnapshotVersion)
+	}
+	if sh.MetaProtocol != SnapshotMetaProtocol {
+		return nil, sh, fmt.Errorf("mismatched meta-protocol of the snapshot: %s, expected: %s", sh.MetaProtocol, SnapshotMetaProtocol)
+	}
+
+	hasher := sha256.New()
+	reader := io.TeeReader(bufio.NewReader(file), hasher)
+	header, keyCount, err := deserialize(reader, sh.Height)
+	if err != nil {
+		return nil, sh, err
+	}
+	// Drain the rest of the content so the checksum covers the whole file.
+	_, err = io.Copy(io.Discard, reader)
+	if err != nil {
+		return nil, sh, err
+	}
+	if checksum := hex.EncodeToString(hasher.Sum(nil)); checksum != sh.Checksum {
+		return nil, sh, fmt.Errorf("mismatched checksum of the snapshot: %s, expected: %s", checksum, sh.Checksum)
+	}
+	if keyCount != sh.KeyCount {
+		return nil, sh, fmt.Errorf("mismatched key count of the snapshot: %d, expected: %d", keyCount, sh.KeyCount)
+	}
+	commitmentBytes := header.Root.Commit().Bytes()
+	if commitment := base64.StdEncoding.EncodeToString(commitmentBytes[:]); commitment != sh.Commitment {
+		return nil, sh, fmt.Errorf("mismatched commitment of the snapshot: %s, expected: %s", commitment, sh.Commitment)
+	}
+	header.Hash = sh.Hash
+	return header, sh, nil
+}
@@ -0,0 +1,129 @@
+package stateless
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+	"github.com/holiman/uint256"
+)
+
+func TestSnapshot(t *testing.T) {
+	header := &Header{Root: verkle.New(), KV: NewMemoryStore(), Height: 779831, IntermediateKV: KeyValueMap{}}
+	for i := range 100 {
+		header.InsertUInt256(GetTickHash("ordi", byte(i)), uint256.NewInt(uint64(i)))
+	}
+	_ = header.Paging(nil, false, nil)
+
+	path := filepath.Join(t.TempDir(), "779832.dat")
+	sh, err := WriteSnapshot(path, header, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sh.KeyCount != 100 || sh.Height != 779832 {
+		t.Fatal("unexpected snapshot header", sh)
+	}
+
+	loaded, loadedHeader, err := LoadSnapshot(path, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *loadedHeader != *sh || loaded.Hash != "hash" || loaded.Height != header.Height {
+		t.Fatal("unexpected loaded snapshot", loadedHeader)
+	}
+	if loaded.Root.Commit().Bytes() != header.Root.Commit().Bytes() {
+		t.Fatal("mismatched commitment of the loaded snapshot")
+	}
+
+	// Corrupt the last byte of the content.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadSnapshot(path, NewMemoryStore()); err == nil {
+		t.Fatal("the corrupted snapshot is loaded")
+	}
+}
+
+func TestSnapshotManager(t *testing.T) {
+	header := &Header{Root: verkle.New(), KV: NewMemoryStore(), IntermediateKV: KeyValueMap{}}
+	header.InsertUInt256(GetTickHash("ordi", Exists), uint256.NewInt(1))
+	_ = header.Paging(nil, false, nil)
+
+	// The snapshot at 1500 is at the height of a published checkpoint.
+	checkpoints := func() ([]uint, error) {
+		return []uint{1500, 3000}, nil
+	}
+	snapshots := NewSnapshotManager(t.TempDir(), 1000, RetentionPolicy{KeepLast: 2, KeepEvery: 5000, KeepHeights: checkpoints})
+	for _, height := range []uint{1000, 1500, 2000, 5000, 6000, 7000, 8000} {
+		header.Height = height
+		if _, err := WriteSnapshot(snapshots.Path(height), header, "hash"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(snapshots.Path(9000)+".tmp", nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := NewJournal(filepath.Join(t.TempDir(), "journal.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	for _, height := range []uint{1000, 1499, 1500, 1501} {
+		if err := j.Append(JournalEntry{Height: height}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	UseJournal(j)
+	defer UseJournal(nil)
+
+	// Nothing is removed if the checkpoint heights are unknown.
+	snapshots.Retention.KeepHeights = func() ([]uint, error) {
+		return nil, errors.New("the outbox is locked")
+	}
+	if removed, err := snapshots.Prune(); err == nil || len(removed) != 0 {
+		t.Fatal("pruned without the checkpoint heights", removed)
+	}
+	snapshots.Retention.KeepHeights = checkpoints
+
+	removed, err := snapshots.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The journal is kept from the oldest kept snapshot.
+	if first, last, _, err := j.Range(); err != nil || first != 1500 || last != 1501 {
+		t.Fatal("unexpected range of the pruned journal", first, last, err)
+	}
+	if len(removed) != 3 || removed[0] != 6000 || removed[1] != 2000 || removed[2] != 1000 {
+		t.Fatal("unexpected removed snapshots", removed)
+	}
+
+	infos, err := snapshots.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := make([]uint, 0)
+	for _, info := range infos {
+		if info.Err != nil {
+			t.Fatal(info.Err)
+		}
+		kept = append(kept, info.Header.Height)
+	}
+	if len(kept) != 4 || kept[0] != 8000 || kept[1] != 7000 || kept[2] != 5000 || kept[3] != 1500 {
+		t.Fatal("unexpected kept snapshots", kept)
+	}
+	if _, err := os.Stat(snapshots.Path(9000) + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("the temporary file is not removed")
+	}
+
+	if _, err := snapshots.Verify(5000, NewMemoryStore()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,97 @@
+package stateless
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ethereum/go-verkle"
+	uint256 "github.com/holiman/uint256"
+)
+
+// StateReader reads the settled state of a header from its store. Unlike the header it never records the accesses,
+// so the queries served between the blocks don't leak into the access list of the next block, and it never walks
+// the verkle tree. The caller must keep the header from being updated during the reads.
+type StateReader struct {
+	KV     StateStore
+	Height uint
+}
+
+// Reader returns the StateReader of the header, the header must have no pending writes.
+func (h *Header) Reader() *StateReader {
+	return &StateReader{KV: h.KV, Height: h.Height}
+}
+
+func (r *StateReader) insert(key []byte, value []byte, nodeResolverFn verkle.NodeResolverFn) {
+	panic(fmt.Errorf("the state at height %d is read only", r.Height))
+}
+
+func (r *StateReader) get(key []byte, nodeResolverFn verkle.NodeResolverFn) []byte {
+	if len(key) != verkle.KeySize {
+		panic(fmt.Errorf("the length the key to get bytes must be %d, current is: %d", verkle.KeySize, len(key)))
+	}
+	value, found, err := r.KV.Get([verkle.KeySize]byte(key))
+	if err != nil {
+		panic(err)
+	}
+	if !found {
+		value = defaultValue()
+	}
+	return value[:]
+}
+
+func (r *StateReader) InsertInscriptionID(key []byte, value string) {
+	r.insert(key, nil, nil)
+}
+
+func (r *StateReader) GetInscriptionID(key []byte) string {
+	// The first Key
+	firstKey := make([]byte, verkle.KeySize)
+	copy(firstKey, key)
+	transactionIDBytes := r.get(firstKey, nil)
+	transactionID := hex.EncodeToString(transactionIDBytes)
+
+	// The second Key
+	secondKey := make([]byte, verkle.KeySize)
+	copy(secondKey, key)
+	secondKey[verkle.StemSize] = firstKey[verkle.StemSize] + byte(1)
+	outputIndexUint256 := r.GetUInt256(secondKey)
+	outputIndex := outputIndexUint256.Dec()
+
+	return transactionID + "i" + outputIndex
+}
+
+func (r *StateReader) InsertUInt256(key []byte, value *uint256.Int) {
+	r.insert(key, nil, nil)
+}
+
+func (r *StateReader) GetUInt256(key []byte) *uint256.Int {
+	res := uint256.NewInt(0)
+	return res.SetBytes(r.get(key, nil))
+}
+
+func (r *StateReader) InsertBytes(key []byte, value []byte) {
+	r.insert(key, nil, nil)
+}
+
+func (r *StateReader) GetBytes(key []byte) []byte {
+	newKey := make([]byte, verkle.KeySize)
+	copy(newKey, key)
+
+	len := r.GetUInt256(newKey).Uint64()
+	if len == 0 {
+		return make([]byte, 0)
+	}
+	requiredSlots := (len + ValueSize - 1) / ValueSize
+
+	padded := make([]byte, 0)
+	for i := range requiredSlots {
+		newKey[verkle.StemSize] = key[verkle.StemSize] + byte(i+1)
+		padded = append(padded, r.get(newKey, nil)...)
+	}
+	res := padded[:len]
+	return res
+}
+
+func (r *StateReader) GetHeight() uint {
+	return r.Height
+}
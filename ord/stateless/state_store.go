@@ -0,0 +1,49 @@
+package stateless
+
+import (
+	"github.com/ethereum/go-verkle"
+)
+
+// MemoryStore keeps all key values in a map, it is the default StateStore.
+type MemoryStore struct {
+	kv KeyValueMap
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		kv: make(KeyValueMap),
+	}
+}
+
+func (m *MemoryStore) Get(key [verkle.KeySize]byte) ([ValueSize]byte, bool, error) {
+	value, found := m.kv[key]
+	return value, found, nil
+}
+
+func (m *MemoryStore) Apply(puts KeyValueMap, deletes [][verkle.KeySize]byte) error {
+	for key, value := range puts {
+		m.kv[key] = value
+	}
+	for _, key := range deletes {
+		delete(m.kv, key)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Iterate(fn func(key [verkle.KeySize]byte, value [ValueSize]byte) error) error {
+	for key, value := range m.kv {
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Clear() error {
+	m.kv = make(KeyValueMap)
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
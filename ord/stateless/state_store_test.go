@@ -0,0 +1,61 @@
+package stateless
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-verkle"
+)
+
+func TestStateStores(t *testing.T) {
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+
+	for name, store := range map[string]StateStore{"memory": NewMemoryStore(), "bolt": bolt} {
+		t.Run(name, func(t *testing.T) {
+			puts := make(KeyValueMap)
+			for i := range 3 {
+				puts[[verkle.KeySize]byte{byte(i)}] = [ValueSize]byte{byte(i + 1)}
+			}
+			if err := store.Apply(puts, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Apply(nil, [][verkle.KeySize]byte{{1}}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, found, err := store.Get([verkle.KeySize]byte{1}); err != nil || found {
+				t.Fatal("deleted key is still found", err)
+			}
+			value, found, err := store.Get([verkle.KeySize]byte{2})
+			if err != nil || !found || value != [ValueSize]byte{3} {
+				t.Fatal("unexpected value", value, found, err)
+			}
+
+			header := Header{Root: verkle.New(), KV: store}
+			var buffer bytes.Buffer
+			if _, err := header.Serialize(&buffer); err != nil {
+				t.Fatal(err)
+			}
+			restored, count, err := Deserialize(&buffer, 1, NewMemoryStore(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys, err := restored.OrderedKeys()
+			if err != nil || len(keys) != 2 || count != 2 {
+				t.Fatal("unexpected keys", keys, err)
+			}
+
+			if err := store.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			if _, found, _ := store.Get([verkle.KeySize]byte{0}); found {
+				t.Fatal("the store is not cleared")
+			}
+		})
+	}
+}
@@ -1,105 +1,311 @@
 package stateless
 
 import (
-	"bytes"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-verkle"
 
 	"github.com/RiemaLabs/modular-indexer-committee/internal/metrics"
+	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
 )
 
-const cachePath = ".cache"
 const fileSuffix = ".dat"
 
-func LoadHeader(enableStateRootCache bool, initHeight uint) *Header {
-	curHeight := initHeight
-	myHeader := Header{
-		Root:           verkle.New(),
-		Height:         curHeight,
-		KV:             make(KeyValueMap),
-		Access:         AccessList{},
-		IntermediateKV: KeyValueMap{},
+// RetentionPolicy decides which snapshots survive the pruning.
+// A snapshot is kept if any of the rules keeps it, all snapshots are kept if no rule is set.
+type RetentionPolicy struct {
+	// Keep the newest N snapshots.
+	KeepLast int
+	// Keep the snapshots whose height is a multiple of K.
+	KeepEvery uint
+	// Keep the snapshots at the returned heights, e.g. the heights of the published checkpoints.
+	// It is called once per pruning, so the heights may change while the indexer runs.
+	KeepHeights func() ([]uint, error)
+}
+
+func (p *RetentionPolicy) isEmpty() bool {
+	return p.KeepLast <= 0 && p.KeepEvery == 0 && p.KeepHeights == nil
+}
+
+// keep reports whether the snapshot at height should be kept, rank is its index from the newest snapshot.
+// protected holds the heights returned by KeepHeights.
+func (p *RetentionPolicy) keep(height uint, rank int, protected map[uint]bool) bool {
+	if p.isEmpty() {
+		return true
 	}
-	metrics.CurrentHeight.Set(float64(myHeader.Height))
-	if enableStateRootCache {
-		files, err := os.ReadDir(cachePath)
-		if err != nil {
-			return &myHeader
-		}
-		// Variables to keep track of the file with the maximum state.height
-		var maxHeight int
-		var maxFile string
-
-		// Iterate through all files
-		for _, file := range files {
-			// Check if the file has the suffix
-			if filepath.Ext(file.Name()) == fileSuffix {
-				heightString := strings.TrimSuffix(file.Name(), fileSuffix)
-				height, err := strconv.Atoi(heightString)
-				if err == nil && height > maxHeight {
-					// Update the maximum state.height and corresponding file name
-					maxHeight = height
-					maxFile = file.Name()
-				}
+	if rank < p.KeepLast {
+		return true
+	}
+	if p.KeepEvery != 0 && height%p.KeepEvery == 0 {
+		return true
+	}
+	return protected[height]
+}
+
+// protected returns the heights kept by KeepHeights.
+func (p *RetentionPolicy) protected() (map[uint]bool, error) {
+	protected := make(map[uint]bool)
+	if p.KeepHeights == nil {
+		return protected, nil
+	}
+	heights, err := p.KeepHeights()
+	if err != nil {
+		return nil, err
+	}
+	for _, height := range heights {
+		protected[height] = true
+	}
+	return protected, nil
+}
+
+// SnapshotManager stores, loads and prunes the snapshots in a directory.
+type SnapshotManager struct {
+	Dir string
+	// A snapshot is created every Interval blocks during the catchup.
+	Interval  uint
+	Retention RetentionPolicy
+	// The loaded headers are detached, e.g. rebuilt while another state is served, see Header.Attach.
+	Detached bool
+}
+
+func NewSnapshotManager(dir string, interval uint, retention RetentionPolicy) *SnapshotManager {
+	return &SnapshotManager{
+		Dir:       dir,
+		Interval:  interval,
+		Retention: retention,
+	}
+}
+
+// Due reports whether a snapshot should be created at height.
+func (m *SnapshotManager) Due(height uint) bool {
+	return m.Interval != 0 && height%m.Interval == 0
+}
+
+func (m *SnapshotManager) Path(height uint) string {
+	return filepath.Join(m.Dir, fmt.Sprintf("%d%s", height, fileSuffix))
+}
+
+// Heights returns the heights of all snapshot files, from the newest to the oldest.
+func (m *SnapshotManager) Heights() ([]uint, error) {
+	files, err := os.ReadDir(m.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []uint{}, nil
+		}
+		return nil, err
+	}
+	heights := make([]uint, 0)
+	for _, file := range files {
+		// Check if the file has the suffix
+		if filepath.Ext(file.Name()) == fileSuffix {
+			heightString := strings.TrimSuffix(file.Name(), fileSuffix)
+			height, err := strconv.ParseUint(heightString, 10, 64)
+			if err == nil {
+				heights = append(heights, uint(height))
 			}
 		}
+	}
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i] > heights[j]
+	})
+	return heights, nil
+}
 
-		if maxFile != "" {
-			data, err := os.ReadFile(filepath.Join(cachePath, maxFile))
-			if err != nil {
-				return &myHeader
-			}
-			var buffer = bytes.NewBuffer(data)
-			log.Println("Start to rebuild verkle tree.")
-			storedState, err := Deserialize(buffer, uint(maxHeight), nil)
-			if err != nil {
-				return &myHeader
-			}
-			log.Println("End to rebuild verkle tree.")
-			return storedState
+// SnapshotInfo describes a snapshot file, Header is nil if the header record can't be read.
+type SnapshotInfo struct {
+	Height uint
+	Path   string
+	Size   int64
+	Header *SnapshotHeader
+	Err    error
+}
+
+// List reads the header records of all snapshots, from the newest to the oldest.
+func (m *SnapshotManager) List() ([]SnapshotInfo, error) {
+	heights, err := m.Heights()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SnapshotInfo, 0, len(heights))
+	for _, height := range heights {
+		info := SnapshotInfo{
+			Height: height,
+			Path:   m.Path(height),
 		}
+		if stat, err := os.Stat(info.Path); err == nil {
+			info.Size = stat.Size()
+		}
+		info.Header, info.Err = ReadSnapshotHeader(info.Path)
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
 
+// Verify fully loads the snapshot at height into the store and checks its content against the header record.
+func (m *SnapshotManager) Verify(height uint, store StateStore) (*SnapshotHeader, error) {
+	_, sh, err := LoadSnapshot(m.Path(height), store)
+	if err != nil {
+		return sh, err
+	}
+	if sh.Height != height {
+		return sh, fmt.Errorf("mismatched height of the snapshot: %d, expected: %d", sh.Height, height)
 	}
-	return &myHeader
+	return sh, nil
 }
 
-func StoreHeader(header *Header, evictHeight uint) error {
-	buffer, err := header.Serialize()
-	bytes := buffer.Bytes()
+// Prune removes the snapshots rejected by the retention policy and the leftover temporary files.
+// The journal in use is pruned along, the entries below the oldest kept snapshot are never replayed.
+// It returns the heights of the removed snapshots.
+func (m *SnapshotManager) Prune() ([]uint, error) {
+	heights, err := m.Heights()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// Nothing is removed if the protected heights are unknown.
+	protected, err := m.Retention.protected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the protected heights: %w", err)
+	}
+	removed := make([]uint, 0)
+	oldest, kept := uint(0), false
+	for rank, height := range heights {
+		if m.Retention.keep(height, rank, protected) {
+			oldest, kept = height, true
+			continue
+		}
+		path := m.Path(height)
+		err := os.Remove(path)
+		if err != nil {
+			log.Printf("Failed to remove old file: %s, err: %v", path, err)
+			continue
+		}
+		removed = append(removed, height)
+	}
+	if journal != nil && kept {
+		err := journal.Prune(oldest)
+		if err != nil {
+			return removed, fmt.Errorf("failed to prune the journal below height %d: %w", oldest, err)
+		}
 	}
 
-	fileName := fmt.Sprintf("%d%s", header.Height, fileSuffix)
-	filePath := filepath.Join(cachePath, fileName)
-	err = os.WriteFile(filePath, bytes, 0666)
+	tmpFiles, err := filepath.Glob(filepath.Join(m.Dir, "*"+fileSuffix+".tmp"))
 	if err != nil {
-		return err
+		return removed, err
+	}
+	for _, tmpFile := range tmpFiles {
+		err := os.Remove(tmpFile)
+		if err != nil {
+			log.Printf("Failed to remove temporary file: %s, err: %v", tmpFile, err)
+		}
+	}
+	return removed, nil
+}
+
+// Load loads the newest valid snapshot not above maxHeight, it returns nil if there is no valid snapshot.
+// A snapshot is valid if its content matches the header record and its block hash matches the one from the getter,
+// otherwise the next-older snapshot is tried.
+func (m *SnapshotManager) Load(ordGetter getter.OrdGetter, store StateStore, maxHeight uint) (*Header, error) {
+	heights, err := m.Heights()
+	if err != nil {
+		log.Printf("Failed to list the snapshots: %v", err)
+		return nil, nil
+	}
+	for _, height := range heights {
+		if height > maxHeight {
+			continue
+		}
+		path := m.Path(height)
+		log.Printf("Start to rebuild verkle tree from the snapshot: %s", path)
+		storedState, sh, err := LoadSnapshot(path, store)
+		if err != nil {
+			log.Printf("Skip the invalid snapshot %s: %v", path, err)
+			continue
+		}
+		if sh.Height != height {
+			log.Printf("Skip the snapshot %s: mismatched height %d", path, sh.Height)
+			continue
+		}
+		hash, err := ordGetter.GetBlockHash(sh.Height)
+		if err != nil {
+			return nil, err
+		}
+		if hash != sh.Hash {
+			log.Printf("Skip the snapshot %s: mismatched block hash %s, expected %s", path, sh.Hash, hash)
+			continue
+		}
+		log.Println("End to rebuild verkle tree.")
+		storedState.detached = m.Detached
+		return storedState, resetNodeStore(storedState)
+	}
+	return nil, nil
+}
+
+// LoadHeader loads the newest valid snapshot not above maxHeight, or creates an empty header at initHeight.
+func (m *SnapshotManager) LoadHeader(ordGetter getter.OrdGetter, store StateStore, enableStateRootCache bool, initHeight uint, maxHeight uint) (*Header, error) {
+	if enableStateRootCache {
+		storedState, err := m.Load(ordGetter, store, maxHeight)
+		if err != nil {
+			return nil, err
+		}
+		if storedState != nil {
+			metrics.CurrentHeight.Set(float64(storedState.Height))
+			return storedState, nil
+		}
+	}
+
+	err := store.Clear()
+	if err != nil {
+		return nil, err
+	}
+	myHeader := Header{
+		Root:           verkle.New(),
+		Height:         initHeight,
+		KV:             store,
+		Access:         AccessList{},
+		IntermediateKV: KeyValueMap{},
+		detached:       m.Detached,
 	}
+	metrics.CurrentHeight.Set(float64(myHeader.Height))
+	return &myHeader, nil
+}
 
-	// Delete old files
-	files, err := os.ReadDir(cachePath)
+// resetNodeStore drops the nodes of the previous run and flushes the tree loaded from the cache.
+func resetNodeStore(header *Header) error {
+	if nodeStore == nil || header.detached {
+		return nil
+	}
+	err := nodeStore.Clear()
 	if err != nil {
 		return err
 	}
-	for _, file := range files {
-		// Check if the file has the suffix
-		if filepath.Ext(file.Name()) == fileSuffix {
-			heightString := strings.TrimSuffix(file.Name(), fileSuffix)
-			height, err := strconv.Atoi(heightString)
-			if err == nil && height < int(evictHeight) {
-				err := os.Remove(filepath.Join(cachePath, file.Name()))
-				if err != nil {
-					log.Printf("Failed to remove old file: %s, err: %v", file.Name(), err)
-				}
-			}
+	return nodeStore.FlushAll(header.Root)
+}
+
+// StoreHeader writes the snapshot of the header and prunes the snapshots by the retention policy.
+// The block hash is queried from the getter if the header doesn't know it, e.g. after the Paging without the hash.
+func (m *SnapshotManager) StoreHeader(ordGetter getter.OrdGetter, header *Header) error {
+	hash := header.Hash
+	if hash == "" {
+		var err error
+		hash, err = ordGetter.GetBlockHash(header.Height)
+		if err != nil {
+			return err
 		}
 	}
-	return nil
+	err := os.MkdirAll(m.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	_, err = WriteSnapshot(m.Path(header.Height), header, hash)
+	if err != nil {
+		return err
+	}
+	_, err = m.Prune()
+	return err
 }
@@ -3,7 +3,6 @@ package stateless
 import (
 	"sync"
 
-	"github.com/RiemaLabs/modular-indexer-committee/ord"
 	"github.com/RiemaLabs/modular-indexer-committee/ord/getter"
 	verkle "github.com/ethereum/go-verkle"
 	uint256 "github.com/holiman/uint256"
@@ -28,6 +27,8 @@ type DiffState struct {
 	Hash   string
 	// ipa.CompressedSize
 	VerkleCommit [32]byte
+	// The digest of the ord transfers of the block, see getter.DigestOrdTransfers.
+	OrdTransDigest [32]byte
 
 	Access AccessList
 }
@@ -39,7 +40,7 @@ type Header struct {
 	Root verkle.VerkleNode
 
 	// All Key Values on the Verkle Tree. It shall be consistent with the Root.
-	KV KeyValueMap
+	KV StateStore
 
 	// The state is after the execution of Block Height.
 	Height uint
@@ -50,8 +51,12 @@ type Header struct {
 
 	// All values being accessed at this height.
 	Access AccessList
+	// The index of each key in Access.Elements, it is cleared along with Access by resetAccess.
+	accessIndex map[[verkle.KeySize]byte]int
 	// The key-value map during the execution of the block.
 	IntermediateKV KeyValueMap
+	// The detached tree stays in memory and never touches the node store, see Attach.
+	detached bool
 
 	sync.RWMutex
 }
@@ -66,12 +71,30 @@ type LightHeader struct {
 }
 
 type Queue struct {
-	Header         *Header
-	History        [ord.BitcoinConfirmations]DiffState
+	Header *Header
+	// The differences of the latest blocks, the reorgs inside this window are recovered from them.
+	History        []DiffState
 	LastStateProof *verkle.Proof
 	sync.RWMutex
 }
 
+// StateStore keeps all key values of the verkle tree outside of the tree itself.
+type StateStore interface {
+	// Get returns the value of the key and whether it exists.
+	Get(key [verkle.KeySize]byte) ([ValueSize]byte, bool, error)
+
+	// Apply writes all puts and deletes atomically.
+	Apply(puts KeyValueMap, deletes [][verkle.KeySize]byte) error
+
+	// Iterate visits all key values, fn must not modify the store.
+	Iterate(fn func(key [verkle.KeySize]byte, value [ValueSize]byte) error) error
+
+	// Clear removes all key values.
+	Clear() error
+
+	Close() error
+}
+
 type KVStorage interface {
 	insert(key []byte, value []byte, nodeResolverFn verkle.NodeResolverFn)
 
@@ -1,10 +1,12 @@
 package stateless
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"unicode"
 
+	"github.com/RiemaLabs/modular-indexer-committee/ord"
 	base58 "github.com/btcsuite/btcd/btcutil/base58"
 	"github.com/ethereum/go-verkle"
 
@@ -19,6 +21,46 @@ var SelfMintEnableHeight uint = 837090
 
 var NodeResolveFn verkle.NodeResolverFn = nil
 
+// The NodeStore used to flush and resolve verkle nodes, the whole tree stays in memory if it is nil.
+var nodeStore *NodeStore = nil
+
+// UseNodeStore makes the verkle tree partially resident, the flushed nodes are resolved from the store on demand.
+func UseNodeStore(store *NodeStore) {
+	nodeStore = store
+	NodeResolveFn = store.Resolve
+}
+
+// The Journal recording the state difference of each paged block, nothing is recorded if it is nil.
+var journal *Journal = nil
+
+// UseJournal makes every paged block appended to the journal.
+func UseJournal(j *Journal) {
+	journal = j
+}
+
+// CloseStores closes the node store and the journal in use, nothing is resolved or recorded afterwards.
+func CloseStores() error {
+	var errs []error
+	if nodeStore != nil {
+		errs = append(errs, nodeStore.Close())
+		nodeStore = nil
+		NodeResolveFn = nil
+	}
+	if journal != nil {
+		errs = append(errs, journal.Close())
+		journal = nil
+	}
+	return errors.Join(errs...)
+}
+
+// The pkscript of a bare OP_RETURN output, transfers sent to it are burned.
+// It is consistent with OPI, other OP_RETURN outputs are treated as normal pkscripts.
+const BurnPkscript ord.Pkscript = "6a"
+
+func isBurnPkscript(pkscript ord.Pkscript) bool {
+	return pkscript == BurnPkscript
+}
+
 func isPositiveNumber(s string, doStrip bool) bool {
 	if doStrip {
 		s = strings.TrimSpace(s)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"log"
 
@@ -14,7 +15,7 @@ import (
 func Test_Reorg(t *testing.T) {
 	var catchupHeight uint = 780000
 	ordGetterTest, arguments := loadMain(782000)
-	queue, _ := CatchupStage(ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
+	queue, _ := CatchupStage(context.Background(), ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
 
 	loadReorg(ordGetterTest, queue, 1)
 
@@ -67,7 +68,7 @@ func Test_Rollingback(t *testing.T) {
 
 func loadRollingback(catchupHeight uint) {
 	ordGetterTest, arguments := loadMain(782000)
-	queue, _ := CatchupStage(ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
+	queue, _ := CatchupStage(context.Background(), ordGetterTest, &arguments, stateless.BRC20StartHeight-1, catchupHeight)
 	lastHistory := queue.History[len(queue.History)-1]
 	preState, _ := stateless.Rollingback(queue.Header, &lastHistory)
 	preBytes := preState.Commit().Bytes()